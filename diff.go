@@ -0,0 +1,171 @@
+package fn
+
+import (
+	"iter"
+	"strconv"
+)
+
+// ChangeKind says how an entry differs between two Maps.
+type ChangeKind int
+
+const (
+	Added   ChangeKind = iota + 1 // the key is only in the newer Map
+	Removed                       // the key is only in the older Map
+	Changed                       // the key is in both Maps with different values
+)
+
+// String returns the name of the kind.
+func (c ChangeKind) String() string {
+	switch c {
+	case Added:
+		return "Added"
+	case Removed:
+		return "Removed"
+	case Changed:
+		return "Changed"
+	}
+	return "ChangeKind(" + strconv.Itoa(int(c)) + ")"
+}
+
+// Change describes one entry that differs between two Maps. Old is the zero
+// value for Added entries and New is the zero value for Removed ones.
+type Change[K any, V Val] struct {
+	Kind     ChangeKind
+	Key      K
+	Old, New V
+}
+
+// Diff returns an iterator over the entries that differ between m and other,
+// as the changes that turn m into other. Values are compared as by
+// [Map.Equal]. The two tries are walked together, so subtrees shared by both
+// maps, such as those of snapshots derived from one another, are skipped
+// without being visited and the cost is proportional to the size of the
+// difference.
+func (m Map[K, V]) Diff(other Map[K, V]) iter.Seq[Change[K, V]] {
+	return func(yield func(Change[K, V]) bool) {
+		d := differ[K, V]{hs: m.hs(), eq: valuesEqual[V], yield: yield}
+		d.diff(m.root, m.aligned(other).root, 0)
+	}
+}
+
+// differ walks two tries in the same hashing scheme, reporting the entries
+// that differ.
+type differ[K any, V Val] struct {
+	hs    Hasher[K]
+	eq    func(a, b V) bool
+	yield func(Change[K, V]) bool
+}
+
+func (d *differ[K, V]) added(l leaf[K, V]) bool {
+	return d.yield(Change[K, V]{Kind: Added, Key: l.key, New: l.val})
+}
+
+func (d *differ[K, V]) removed(l leaf[K, V]) bool {
+	return d.yield(Change[K, V]{Kind: Removed, Key: l.key, Old: l.val})
+}
+
+// both reports la and lb, leaves holding the same key, if their values differ.
+func (d *differ[K, V]) both(la, lb leaf[K, V]) bool {
+	if d.eq(la.val, lb.val) {
+		return true
+	}
+	return d.yield(Change[K, V]{Kind: Changed, Key: la.key, Old: la.val, New: lb.val})
+}
+
+// diff reports the differences between a and b, two nodes at the same depth.
+// It returns false if the consumer stopped the iteration.
+func (d *differ[K, V]) diff(a, b node[K, V], depth uint) bool {
+	if a.same(b) {
+		return true
+	}
+
+	if depth >= maxDepth {
+		for _, la := range a.data {
+			j := b.find(d.hs, la.key)
+			if j < 0 && !d.removed(la) || j >= 0 && !d.both(la, b.data[j]) {
+				return false
+			}
+		}
+		for _, lb := range b.data {
+			if a.find(d.hs, lb.key) < 0 && !d.added(lb) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for slots := a.dataMap | a.nodeMap | b.dataMap | b.nodeMap; slots != 0; {
+		bit := slots & -slots
+		slots &^= bit
+
+		aLeaf, aNode := a.dataMap&bit != 0, a.nodeMap&bit != 0
+		bLeaf, bNode := b.dataMap&bit != 0, b.nodeMap&bit != 0
+
+		ok := true
+		switch {
+		case aLeaf && bLeaf:
+			la, lb := a.data[a.dataIndex(bit)], b.data[b.dataIndex(bit)]
+			if d.hs.Equal(la.key, lb.key) {
+				ok = d.both(la, lb)
+			} else {
+				ok = d.removed(la) && d.added(lb)
+			}
+		case aLeaf && bNode:
+			la := a.data[a.dataIndex(bit)]
+			ok = d.diff(singleton(la, hashOf(d.hs, la.key), depth+1), *b.nodes[b.nodeIndex(bit)], depth+1)
+		case aNode && bLeaf:
+			lb := b.data[b.dataIndex(bit)]
+			ok = d.diff(*a.nodes[a.nodeIndex(bit)], singleton(lb, hashOf(d.hs, lb.key), depth+1), depth+1)
+		case aNode && bNode:
+			ok = d.diff(*a.nodes[a.nodeIndex(bit)], *b.nodes[b.nodeIndex(bit)], depth+1)
+		case aLeaf:
+			ok = d.removed(a.data[a.dataIndex(bit)])
+		case aNode:
+			ok = a.nodes[a.nodeIndex(bit)].forEach(func(k K, v V) bool {
+				return d.removed(leaf[K, V]{key: k, val: v})
+			})
+		case bLeaf:
+			ok = d.added(b.data[b.dataIndex(bit)])
+		case bNode:
+			ok = b.nodes[b.nodeIndex(bit)].forEach(func(k K, v V) bool {
+				return d.added(leaf[K, V]{key: k, val: v})
+			})
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Patch is a list of changes that turns one Map snapshot into another. It
+// is usually produced by [Map.PatchTo] and replayed with [Patch.Apply], for
+// example to ship only what changed between two snapshots.
+type Patch[K any, V Val] []Change[K, V]
+
+// PatchTo returns the Patch that turns m into other. It collects [Map.Diff].
+func (m Map[K, V]) PatchTo(other Map[K, V]) Patch[K, V] {
+	var p Patch[K, V]
+	for c := range m.Diff(other) {
+		p = append(p, c)
+	}
+	return p
+}
+
+// Apply returns m with every change in p applied: Added and Changed entries
+// are set to their New value and Removed entries are deleted. Applying
+// m.PatchTo(other) to m yields a Map equal to other.
+func (p Patch[K, V]) Apply(m Map[K, V]) Map[K, V] {
+	if len(p) == 0 {
+		return m
+	}
+	return m.Batch(func(b *Builder[K, V]) {
+		for _, c := range p {
+			if c.Kind == Removed {
+				b.Delete(c.Key)
+			} else {
+				b.Set(c.Key, c.New)
+			}
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package fn
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+// checkDiff verifies that a.Diff(b) reports exactly the differences between
+// the two maps, and that the matching Patch turns a into b.
+func checkDiff(t *testing.T, a, b Map[int, int]) {
+	t.Helper()
+	am, bm := ToMap(a), ToMap(b)
+
+	seen := make(map[int]bool)
+	for c := range a.Diff(b) {
+		if seen[c.Key] {
+			t.Errorf("key %d reported twice", c.Key)
+		}
+		seen[c.Key] = true
+
+		av, inA := am[c.Key]
+		bv, inB := bm[c.Key]
+		var want Change[int, int]
+		switch {
+		case inA && inB:
+			want = Change[int, int]{Kind: Changed, Key: c.Key, Old: av, New: bv}
+		case inA:
+			want = Change[int, int]{Kind: Removed, Key: c.Key, Old: av}
+		default:
+			want = Change[int, int]{Kind: Added, Key: c.Key, New: bv}
+		}
+		if c != want {
+			t.Errorf("expected %+v, got %+v", want, c)
+		}
+	}
+
+	for k, v := range am {
+		if bv, ok := bm[k]; (!ok || bv != v) && !seen[k] {
+			t.Errorf("key %d differs but was not reported", k)
+		}
+	}
+	for k := range bm {
+		if _, ok := am[k]; !ok && !seen[k] {
+			t.Errorf("added key %d was not reported", k)
+		}
+	}
+
+	if got := a.PatchTo(b).Apply(a); !got.Equal(b) {
+		t.Errorf("expected applying the patch to reproduce the target")
+	}
+}
+
+func TestMapDiffRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	for _, size := range []int{1, 10, 1000} {
+		for _, edits := range []int{0, 5, 200} {
+			a, b := randomMaps(r, size, edits)
+			t.Run(fmt.Sprintf("size=%d/edits=%d", size, edits), func(t *testing.T) {
+				checkDiff(t, a, b)
+				checkDiff(t, b, a)
+				checkDiff(t, a, NewMap[int, int]())
+				checkDiff(t, NewMap[int, int](), b)
+			})
+		}
+	}
+}
+
+func TestMapDiffCollisions(t *testing.T) {
+	for _, mask := range []uint64{0x7, 0} {
+		t.Run(fmt.Sprintf("mask=%#x", mask), func(t *testing.T) {
+			forceCollisions(t, mask)
+			r := rand.New(rand.NewPCG(5, 6))
+			a, b := randomMaps(r, 100, 50)
+			checkDiff(t, a, b)
+			checkDiff(t, b, a)
+		})
+	}
+}
+
+func TestMapDiffSkipsShared(t *testing.T) {
+	b := NewBuilder[int, int]()
+	for i := range 10000 {
+		b.Set(i, i)
+	}
+	a := b.Build()
+	next := a.Set(42, -1).Delete(7).Set(-5, 5)
+
+	compared := 0
+	var changes []Change[int, int]
+	d := differ[int, int]{
+		hs: a.hs(),
+		eq: func(x, y int) bool {
+			compared++
+			return x == y
+		},
+		yield: func(c Change[int, int]) bool {
+			changes = append(changes, c)
+			return true
+		},
+	}
+	d.diff(a.root, next.root, 0)
+
+	if len(changes) != 3 {
+		t.Errorf("expected 3 changes, got %+v", changes)
+	}
+	if compared > 100 {
+		t.Errorf("expected shared subtrees to be skipped, compared %d values", compared)
+	}
+}
+
+func TestMapDiffEarlyStop(t *testing.T) {
+	a := NewMap[int, int]()
+	b := a
+	for i := range 100 {
+		b = b.Set(i, i)
+	}
+	n := 0
+	for range a.Diff(b) {
+		n++
+		if n == 3 {
+			break
+		}
+	}
+	if n != 3 {
+		t.Errorf("expected iteration to stop after 3 changes, got %d", n)
+	}
+}
+
+func TestMapDiffMixedHashers(t *testing.T) {
+	a := NewMap[string, int]().Set("a", 1).Set("b", 2)
+	b := NewStableMap[string, int](0).Set("b", 20).Set("c", 3)
+
+	got := make(map[string]int)
+	for c := range a.Diff(b) {
+		got[c.Key] = int(c.Kind)
+	}
+	want := map[string]int{"a": int(Removed), "b": int(Changed), "c": int(Added)}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if p := a.PatchTo(b); !p.Apply(a).Equal(b) {
+		t.Errorf("expected patch %+v to reproduce the target", p)
+	}
+}
+
+func TestChangeKindString(t *testing.T) {
+	for k, want := range map[ChangeKind]string{Added: "Added", Removed: "Removed", Changed: "Changed", 9: "ChangeKind(9)"} {
+		if got := k.String(); got != want {
+			t.Errorf("expected %q, got %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package fn
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// binaryVersion is the first byte of every binary-encoded Map. It changes
+// whenever the layout does, so old data is rejected instead of misread.
+const binaryVersion = 1
+
+// ErrInvalidEncoding is returned when decoding malformed binary data.
+var ErrInvalidEncoding = errors.New("fn: invalid binary encoding")
+
+// Codec encodes and decodes the keys or values of a Map for
+// [Map.MarshalBinaryWith] and [Map.UnmarshalBinaryWith]. Each encoded item
+// is length-prefixed by the Map encoding, so DecodeBinary receives exactly
+// the bytes AppendBinary produced.
+type Codec[T any] interface {
+	AppendBinary(b []byte, v T) ([]byte, error)
+	DecodeBinary(data []byte) (T, error)
+}
+
+// DefaultCodec returns the Codec used by [Map.MarshalBinary]. It encodes
+// strings and byte slices as their bytes; booleans, integers and floats in a
+// compact platform-independent form; types implementing
+// [encoding.BinaryMarshaler] and [encoding.BinaryUnmarshaler] with their own
+// methods; and anything else with [encoding/gob], which is much slower.
+// The choice is made from T itself, not from the dynamic type of a value, so
+// an interface type such as any is always encoded with gob, which records
+// the dynamic type; types other than Go's basic ones must be registered with
+// [gob.Register] to be decoded.
+func DefaultCodec[T any]() Codec[T] {
+	return defaultCodec[T]{}
+}
+
+type defaultCodec[T any] struct{}
+
+func (defaultCodec[T]) AppendBinary(b []byte, v T) ([]byte, error) {
+	t := reflect.TypeFor[T]()
+	if t.Kind() == reflect.Interface {
+		return appendGob(b, v)
+	}
+
+	// Common types are encoded directly, without reflection. T is not an
+	// interface, so the dynamic type of v is T.
+	switch x := any(v).(type) {
+	case string:
+		return append(b, x...), nil
+	case []byte:
+		return append(b, x...), nil
+	case int:
+		return binary.AppendVarint(b, int64(x)), nil
+	case int64:
+		return binary.AppendVarint(b, x), nil
+	case uint64:
+		return binary.AppendUvarint(b, x), nil
+	}
+	if marshalsBinary(t) {
+		if x, ok := any(&v).(encoding.BinaryAppender); ok {
+			return x.AppendBinary(b)
+		}
+		data, err := any(&v).(encoding.BinaryMarshaler).MarshalBinary()
+		return append(b, data...), err
+	}
+
+	rv := reflect.ValueOf(&v).Elem()
+	switch rv.Kind() {
+	case reflect.String:
+		return append(b, rv.String()...), nil
+	case reflect.Bool:
+		if rv.Bool() {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return binary.AppendVarint(b, rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return binary.AppendUvarint(b, rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(rv.Float())), nil
+	}
+	return appendGob(b, v)
+}
+
+func (defaultCodec[T]) DecodeBinary(data []byte) (T, error) {
+	var v T
+	t := reflect.TypeFor[T]()
+	if t.Kind() == reflect.Interface {
+		err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+		return v, err
+	}
+
+	switch p := any(&v).(type) {
+	case *string:
+		*p = string(data)
+		return v, nil
+	case *[]byte:
+		*p = bytes.Clone(data)
+		return v, nil
+	case *int:
+		x, n := binary.Varint(data)
+		if n != len(data) || int64(int(x)) != x {
+			return v, fmt.Errorf("%w: bad int", ErrInvalidEncoding)
+		}
+		*p = int(x)
+		return v, nil
+	case *int64:
+		x, n := binary.Varint(data)
+		if n != len(data) {
+			return v, fmt.Errorf("%w: bad int64", ErrInvalidEncoding)
+		}
+		*p = x
+		return v, nil
+	case *uint64:
+		x, n := binary.Uvarint(data)
+		if n != len(data) {
+			return v, fmt.Errorf("%w: bad uint64", ErrInvalidEncoding)
+		}
+		*p = x
+		return v, nil
+	}
+	if marshalsBinary(t) {
+		return v, any(&v).(encoding.BinaryUnmarshaler).UnmarshalBinary(data)
+	}
+
+	rv := reflect.ValueOf(&v).Elem()
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(string(data))
+		return v, nil
+	case reflect.Bool:
+		if len(data) != 1 || data[0] > 1 {
+			return v, fmt.Errorf("%w: bad %v", ErrInvalidEncoding, rv.Type())
+		}
+		rv.SetBool(data[0] == 1)
+		return v, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, n := binary.Varint(data)
+		if n != len(data) || rv.OverflowInt(x) {
+			return v, fmt.Errorf("%w: bad %v", ErrInvalidEncoding, rv.Type())
+		}
+		rv.SetInt(x)
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		x, n := binary.Uvarint(data)
+		if n != len(data) || rv.OverflowUint(x) {
+			return v, fmt.Errorf("%w: bad %v", ErrInvalidEncoding, rv.Type())
+		}
+		rv.SetUint(x)
+		return v, nil
+	case reflect.Float32, reflect.Float64:
+		if len(data) != 8 {
+			return v, fmt.Errorf("%w: bad %v", ErrInvalidEncoding, rv.Type())
+		}
+		rv.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(data)))
+		return v, nil
+	}
+
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+// marshalsBinary reports whether values of type t encode and decode
+// themselves, through MarshalBinary and UnmarshalBinary methods on t or *t.
+func marshalsBinary(t reflect.Type) bool {
+	p := reflect.PointerTo(t)
+	return p.Implements(reflect.TypeFor[encoding.BinaryMarshaler]()) &&
+		p.Implements(reflect.TypeFor[encoding.BinaryUnmarshaler]())
+}
+
+// appendGob appends v encoded with gob to b. Encoding through a pointer
+// keeps interface types intact, so gob records the dynamic type of v.
+func appendGob[T any](b []byte, v T) ([]byte, error) {
+	buf := bytes.NewBuffer(b)
+	if err := gob.NewEncoder(buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalBinary implements [encoding.BinaryMarshaler], encoding keys and
+// values with [DefaultCodec]. The encoding is a version byte, the entry count,
+// and then each key and value, all prefixed with their length as uvarints.
+func (m Map[K, V]) MarshalBinary() ([]byte, error) {
+	return m.MarshalBinaryWith(DefaultCodec[K](), DefaultCodec[V]())
+}
+
+// MarshalBinaryWith encodes m like [Map.MarshalBinary], using kc and vc to
+// encode its keys and values.
+func (m Map[K, V]) MarshalBinaryWith(kc Codec[K], vc Codec[V]) ([]byte, error) {
+	b := binary.AppendUvarint([]byte{binaryVersion}, uint64(m.len))
+	var err error
+	var item []byte
+	m.ForEach(func(k K, v V) bool {
+		if item, err = kc.AppendBinary(item[:0], k); err != nil {
+			err = fmt.Errorf("cannot encode key %v: %w", k, err)
+			return false
+		}
+		b = binary.AppendUvarint(b, uint64(len(item)))
+		b = append(b, item...)
+
+		if item, err = vc.AppendBinary(item[:0], v); err != nil {
+			err = fmt.Errorf("cannot encode value for key %v: %w", k, err)
+			return false
+		}
+		b = binary.AppendUvarint(b, uint64(len(item)))
+		b = append(b, item...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// UnmarshalBinary implements [encoding.BinaryUnmarshaler], decoding data
+// produced by [Map.MarshalBinary]. The Map keeps its hasher.
+func (m *Map[K, V]) UnmarshalBinary(data []byte) error {
+	return m.UnmarshalBinaryWith(data, DefaultCodec[K](), DefaultCodec[V]())
+}
+
+// UnmarshalBinaryWith decodes data produced by [Map.MarshalBinaryWith] with
+// the same codecs. Malformed data, including duplicate keys and trailing
+// bytes, is rejected with an error wrapping [ErrInvalidEncoding]; m is only
+// modified if decoding succeeds.
+func (m *Map[K, V]) UnmarshalBinaryWith(data []byte, kc Codec[K], vc Codec[V]) error {
+	if len(data) == 0 || data[0] != binaryVersion {
+		return fmt.Errorf("%w: unsupported version", ErrInvalidEncoding)
+	}
+	data = data[1:]
+
+	count, n := binary.Uvarint(data)
+	// Every entry takes at least two bytes, which bounds a corrupt count
+	if n <= 0 || count > uint64(len(data)-n)/2 {
+		return fmt.Errorf("%w: bad entry count", ErrInvalidEncoding)
+	}
+	data = data[n:]
+
+	// next splits the next length-prefixed item off data
+	next := func() ([]byte, bool) {
+		size, n := binary.Uvarint(data)
+		if n <= 0 || size > uint64(len(data)-n) {
+			return nil, false
+		}
+		item := data[n : n+int(size)]
+		data = data[n+int(size):]
+		return item, true
+	}
+
+	b := NewBuilderWith[K, V](m.hasher)
+	for i := range count {
+		kb, ok := next()
+		if !ok {
+			return fmt.Errorf("%w: truncated key %d", ErrInvalidEncoding, i)
+		}
+		vb, ok := next()
+		if !ok {
+			return fmt.Errorf("%w: truncated value %d", ErrInvalidEncoding, i)
+		}
+		k, err := kc.DecodeBinary(kb)
+		if err != nil {
+			return fmt.Errorf("cannot decode key %d: %w", i, err)
+		}
+		v, err := vc.DecodeBinary(vb)
+		if err != nil {
+			return fmt.Errorf("cannot decode value for key %v: %w", k, err)
+		}
+		if b.Set(k, v).Len() != int(i)+1 {
+			return fmt.Errorf("%w: duplicate key %v", ErrInvalidEncoding, k)
+		}
+	}
+	if len(data) != 0 {
+		return fmt.Errorf("%w: %d trailing bytes", ErrInvalidEncoding, len(data))
+	}
+
+	*m = b.Build()
+	return nil
+}
+
+// GobEncode implements [gob.GobEncoder] using [Map.MarshalBinary].
+func (m Map[K, V]) GobEncode() ([]byte, error) {
+	return m.MarshalBinary()
+}
+
+// GobDecode implements [gob.GobDecoder] using [Map.UnmarshalBinary].
+func (m *Map[K, V]) GobDecode(data []byte) error {
+	return m.UnmarshalBinary(data)
+}
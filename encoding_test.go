@@ -0,0 +1,250 @@
+package fn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// roundTrip encodes m with MarshalBinary and decodes the result into into.
+func roundTrip[K any, V Val](t *testing.T, m Map[K, V], into Map[K, V]) Map[K, V] {
+	t.Helper()
+	data, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary error: %v", err)
+	}
+	if err := into.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary error: %v", err)
+	}
+	return into
+}
+
+func TestMapBinaryRoundTrip(t *testing.T) {
+	var ints Map[int, string]
+	for i := -500; i < 500; i++ {
+		ints = ints.Set(i, strconv.Itoa(i))
+	}
+	if got := roundTrip(t, ints, Map[int, string]{}); !got.Equal(ints) {
+		t.Error("int keys did not round trip")
+	}
+
+	type id string
+	type level int8
+	type rec struct {
+		Name string
+		Tags []string
+	}
+	small := NewMap[id, level]().Set("a", -128).Set("b", 127)
+	if got := roundTrip(t, small, Map[id, level]{}); !got.Equal(small) {
+		t.Error("named types did not round trip")
+	}
+
+	floats := NewMap[float32, bool]().Set(1.5, true).Set(-2, false)
+	if got := roundTrip(t, floats, Map[float32, bool]{}); !got.Equal(floats) {
+		t.Error("floats and bools did not round trip")
+	}
+
+	recs := NewMap[uint16, rec]().Set(1, rec{"x", []string{"a"}})
+	got := roundTrip(t, recs, Map[uint16, rec]{})
+	if r, _ := got.Get(1); r.Name != "x" || len(r.Tags) != 1 {
+		t.Errorf("expected structs to round trip through gob, got %+v", r)
+	}
+
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	times := NewMap[string, time.Time]().Set("t", when)
+	if v, _ := roundTrip(t, times, Map[string, time.Time]{}).Get("t"); !v.Equal(when) {
+		t.Errorf("expected binary marshalers to be used, got %v", v)
+	}
+
+	nested := NewMap[string, Map[string, int]]().Set("inner", NewMap[string, int]().Set("x", 1))
+	inner, _ := roundTrip(t, nested, Map[string, Map[string, int]]{}).Get("inner")
+	if v, _ := inner.Get("x"); v != 1 {
+		t.Errorf("expected nested maps to round trip, got %v", v)
+	}
+
+	// Interface values are gob-encoded with their dynamic types
+	anys := NewMap[string, any]().Set("a", 1).Set("b", "x").Set("c", []int{1, 2}).Set("d", nil)
+	gotAnys := roundTrip(t, anys, Map[string, any]{})
+	if a, _ := gotAnys.Get("a"); a != 1 {
+		t.Errorf("expected a=1 as an int, got %#v", a)
+	}
+	if b, _ := gotAnys.Get("b"); b != "x" {
+		t.Errorf("expected b=x, got %#v", b)
+	}
+	if c, _ := gotAnys.Get("c"); !reflect.DeepEqual(c, []int{1, 2}) {
+		t.Errorf("expected c=[1 2], got %#v", c)
+	}
+	if d, ok := gotAnys.Get("d"); !ok || d != nil || gotAnys.Len() != 4 {
+		t.Errorf("expected d=nil, got %#v, %v", d, ok)
+	}
+
+	empty := roundTrip(t, NewMap[string, int](), NewMap[string, int]().Set("stale", 1))
+	if empty.Len() != 0 {
+		t.Errorf("expected decoding an empty map to replace the receiver, got len %d", empty.Len())
+	}
+}
+
+func TestMapBinaryKeepsHasher(t *testing.T) {
+	keys := NewMapWith[[]byte, int](BytesHasher{}).Set([]byte("a"), 1).Set([]byte("b"), 2)
+	got := roundTrip(t, keys, NewMapWith[[]byte, int](BytesHasher{}))
+	if v, _ := got.Get([]byte("b")); v != 2 || got.Len() != 2 {
+		t.Errorf("expected byte slice keys to round trip, got len %d", got.Len())
+	}
+
+	folded := roundTrip(t, NewMap[string, int]().Set("Key", 1), NewMapWith[string, int](FoldHasher{}))
+	if !folded.Has("KEY") {
+		t.Error("expected the decoded map to keep its hasher")
+	}
+}
+
+// fixedCodec encodes uint32 values as four big-endian bytes.
+type fixedCodec struct{}
+
+func (fixedCodec) AppendBinary(b []byte, v uint32) ([]byte, error) {
+	return binary.BigEndian.AppendUint32(b, v), nil
+}
+
+func (fixedCodec) DecodeBinary(data []byte) (uint32, error) {
+	if len(data) != 4 {
+		return 0, errors.New("expected 4 bytes")
+	}
+	return binary.BigEndian.Uint32(data), nil
+}
+
+func TestMapBinaryCodec(t *testing.T) {
+	m := NewMap[string, uint32]().Set("a", 1).Set("b", 1<<30)
+	data, err := m.MarshalBinaryWith(DefaultCodec[string](), fixedCodec{})
+	if err != nil {
+		t.Fatalf("MarshalBinaryWith error: %v", err)
+	}
+	// version, count, then 1+1 bytes of key and 1+4 bytes of value per entry
+	if len(data) != 2+2*7 {
+		t.Errorf("expected the custom codec to be used, got %d bytes", len(data))
+	}
+
+	var got Map[string, uint32]
+	if err := got.UnmarshalBinaryWith(data, DefaultCodec[string](), fixedCodec{}); err != nil {
+		t.Fatalf("UnmarshalBinaryWith error: %v", err)
+	}
+	if !got.Equal(m) {
+		t.Error("expected the custom codec to round trip")
+	}
+}
+
+func TestMapBinaryErrors(t *testing.T) {
+	valid, _ := NewMap[string, int]().Set("a", 1).MarshalBinary()
+
+	tests := map[string][]byte{
+		"empty":     nil,
+		"version":   append([]byte{9}, valid[1:]...),
+		"count":     {binaryVersion, 100, 1, 'a'},
+		"truncated": valid[:len(valid)-1],
+		"trailing":  append(bytes.Clone(valid), 0),
+		"duplicate": {binaryVersion, 2, 1, 'a', 1, 2, 1, 'a', 1, 4},
+		"value":     {binaryVersion, 1, 1, 'a', 2, 0x80, 0x80},
+	}
+	for name, data := range tests {
+		m := NewMap[string, int]().Set("kept", 1)
+		err := m.UnmarshalBinary(data)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		if name != "value" && !errors.Is(err, ErrInvalidEncoding) {
+			t.Errorf("%s: expected ErrInvalidEncoding, got %v", name, err)
+		}
+		if !m.Has("kept") || m.Len() != 1 {
+			t.Errorf("%s: expected the receiver to be unchanged", name)
+		}
+	}
+
+	// Values that do not fit the target type are rejected
+	wide, _ := NewMap[string, int]().Set("a", 1000).MarshalBinary()
+	var narrow Map[string, int8]
+	if err := narrow.UnmarshalBinary(wide); !errors.Is(err, ErrInvalidEncoding) {
+		t.Errorf("expected an overflow error, got %v", err)
+	}
+}
+
+func TestMapGob(t *testing.T) {
+	type snapshot struct {
+		Version int
+		State   Map[string, int]
+	}
+	in := snapshot{Version: 3, State: NewMap[string, int]().Set("a", 1).Set("b", 2)}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	var out snapshot
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if out.Version != 3 || !out.State.Equal(in.State) {
+		t.Errorf("expected gob round trip, got version %d and %v", out.Version, ToMap(out.State))
+	}
+}
+
+func FuzzMapUnmarshalBinary(f *testing.F) {
+	for _, m := range []Map[string, int]{
+		NewMap[string, int](),
+		NewMap[string, int]().Set("a", 1),
+		NewMap[string, int]().Set("", -1).Set("key", 1<<40).Set("x", 0),
+	} {
+		data, _ := m.MarshalBinary()
+		f.Add(data)
+	}
+	for _, m := range []Map[string, any]{
+		NewMap[string, any]().Set("a", 1),
+		NewMap[string, any]().Set("a", "x").Set("b", 2.5).Set("c", nil),
+	} {
+		data, _ := m.MarshalBinary()
+		f.Add(data)
+	}
+	f.Add([]byte{binaryVersion, 0xff, 0xff, 0xff, 0xff, 0x0f})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzRoundTrip[int](t, data)
+		fuzzRoundTrip[any](t, data)
+	})
+}
+
+// fuzzRoundTrip checks that data, if it decodes as a Map[string, V], encodes
+// and decodes back to the same Map.
+func fuzzRoundTrip[V any](t *testing.T, data []byte) {
+	var m Map[string, V]
+	if err := m.UnmarshalBinary(data); err != nil {
+		return
+	}
+	if c := m.root.count(); c != m.Len() {
+		t.Fatalf("Len %d disagrees with trie count %d", m.Len(), c)
+	}
+
+	again, err := m.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary error: %v", err)
+	}
+	var m2 Map[string, V]
+	if err := m2.UnmarshalBinary(again); err != nil {
+		t.Fatalf("re-decoding failed: %v", err)
+	}
+	if m2.Len() != m.Len() {
+		t.Fatalf("re-decoded map has len %d, expected %d", m2.Len(), m.Len())
+	}
+	// Values are compared by their encodings, which also handles NaN
+	c := DefaultCodec[V]()
+	for k, v := range m.All() {
+		v2, ok := m2.Get(k)
+		want, _ := c.AppendBinary(nil, v)
+		got, _ := c.AppendBinary(nil, v2)
+		if !ok || !bytes.Equal(got, want) {
+			t.Fatalf("re-decoded %q = %#v, expected %#v", k, v2, v)
+		}
+	}
+}
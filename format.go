@@ -0,0 +1,173 @@
+package fn
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+)
+
+// String returns the Map in the form {a: 1, b: 2}, as printed by %v.
+func (m Map[K, V]) String() string {
+	return fmt.Sprint(m)
+}
+
+// GoString returns a Go expression that builds the Map, as printed by %#v.
+func (m Map[K, V]) GoString() string {
+	return fmt.Sprintf("%#v", m)
+}
+
+// Format implements [fmt.Formatter]. The Map prints as {k: v, ...}, with
+// keys sorted if their type is an integer, float or string and in iteration
+// order otherwise, and with each key and value formatted by the same verb
+// and flags, so %+v and %q apply to the entries. %#v prints a chain of
+// [Map.Set] calls on [NewMap], or on [NewMapWith] if the Map has a custom
+// hasher, so that the keys and values are typed as K and V.
+func (m Map[K, V]) Format(s fmt.State, verb rune) {
+	entries := m.sortedEntries()
+	if verb == 'v' && s.Flag('#') {
+		if !isDefaultHasher(m.hasher) {
+			fmt.Fprintf(s, "fn.NewMapWith[%s, %s](%#v)", typeName[K](), typeName[V](), m.hasher)
+		} else {
+			fmt.Fprintf(s, "fn.NewMap[%s, %s]()", typeName[K](), typeName[V]())
+		}
+		for _, e := range entries {
+			fmt.Fprintf(s, ".Set(%s, %s)", goValue(e.key), goValue(e.val))
+		}
+		return
+	}
+
+	format := fmt.FormatString(s, verb)
+	fmt.Fprint(s, "{")
+	for i, e := range entries {
+		if i > 0 {
+			fmt.Fprint(s, ", ")
+		}
+		fmt.Fprintf(s, format+": "+format, e.key, e.val)
+	}
+	fmt.Fprint(s, "}")
+}
+
+// sortedEntries returns the entries of m, sorted by key if the key type is
+// ordered and in iteration order otherwise.
+func (m Map[K, V]) sortedEntries() []leaf[K, V] {
+	entries := make([]leaf[K, V], 0, m.len)
+	m.ForEach(func(k K, v V) bool {
+		entries = append(entries, leaf[K, V]{key: k, val: v})
+		return true
+	})
+	if isOrdered[K]() {
+		slices.SortFunc(entries, func(a, b leaf[K, V]) int { return compareOrdered(a.key, b.key) })
+	}
+	return entries
+}
+
+// String returns Some(v) or None, as printed by %v.
+func (o Option[T]) String() string {
+	return fmt.Sprint(o)
+}
+
+// GoString returns a Go expression that builds the Option, as printed by %#v.
+func (o Option[T]) GoString() string {
+	return fmt.Sprintf("%#v", o)
+}
+
+// Format implements [fmt.Formatter]. The Option prints as Some(v), with v
+// formatted by the same verb and flags, or as None. %#v prints a call to
+// [Some] or [None].
+func (o Option[T]) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('#') && o.hasSome:
+		fmt.Fprintf(s, "fn.Some[%s](%#v)", typeName[T](), o.val)
+	case verb == 'v' && s.Flag('#'):
+		fmt.Fprintf(s, "fn.None[%s]()", typeName[T]())
+	case o.hasSome:
+		fmt.Fprintf(s, "Some("+fmt.FormatString(s, verb)+")", o.val)
+	default:
+		fmt.Fprint(s, "None")
+	}
+}
+
+// String returns Ok(v) or Err(msg), as printed by %v.
+func (r Result[T]) String() string {
+	return fmt.Sprint(r)
+}
+
+// GoString returns a Go expression that builds the Result, as printed by %#v.
+func (r Result[T]) GoString() string {
+	return fmt.Sprintf("%#v", r)
+}
+
+// Format implements [fmt.Formatter]. The Result prints as Ok(v), with v
+// formatted by the same verb and flags, or as Err(msg). %+v also lists the
+// errors that the error wraps, one per line, with their types. %#v prints a
+// call to [Ok] or [Err].
+func (r Result[T]) Format(s fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && s.Flag('#') && r.err == nil:
+		fmt.Fprintf(s, "fn.Ok[%s](%#v)", typeName[T](), r.val)
+	case verb == 'v' && s.Flag('#'):
+		fmt.Fprintf(s, "fn.Err[%s](%#v)", typeName[T](), r.err)
+	case r.err == nil:
+		fmt.Fprintf(s, "Ok("+fmt.FormatString(s, verb)+")", r.val)
+	case verb == 'v' && s.Flag('+'):
+		fmt.Fprintf(s, "Err(%+v", r.err)
+		for _, cause := range causes(r.err) {
+			fmt.Fprintf(s, "\n\tcaused by %T: %v", cause, cause)
+		}
+		fmt.Fprint(s, ")")
+	default:
+		fmt.Fprintf(s, "Err(%v)", r.err)
+	}
+}
+
+// causes returns the errors wrapped by err, directly or indirectly, in
+// depth-first order.
+func causes(err error) []error {
+	var wrapped []error
+	switch x := err.(type) {
+	case interface{ Unwrap() error }:
+		if e := x.Unwrap(); e != nil {
+			wrapped = []error{e}
+		}
+	case interface{ Unwrap() []error }:
+		wrapped = x.Unwrap()
+	}
+	var all []error
+	for _, e := range wrapped {
+		all = append(all, e)
+		all = append(all, causes(e)...)
+	}
+	return all
+}
+
+// isOrdered reports whether the underlying type of K is an integer, float or
+// string, so that [compareOrdered] can sort keys of that type.
+func isOrdered[K any]() bool {
+	switch reflect.TypeFor[K]().Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// goValue returns v printed with %#v, except that a nil interface value is
+// written as nil.
+func goValue(v any) string {
+	if v == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%#v", v)
+}
+
+// typeName returns the name of T for %#v output, as reported by reflect
+// except that the empty interface is written as any.
+func typeName[T any]() string {
+	t := reflect.TypeFor[T]()
+	if t == reflect.TypeFor[any]() {
+		return "any"
+	}
+	return t.String()
+}
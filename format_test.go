@@ -0,0 +1,103 @@
+package fn
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestMapFormat(t *testing.T) {
+	m := MapFromPairs[string, int]("b", 2, "a", 1, "c", 3)
+	tests := []struct {
+		format string
+		value  any
+		want   string
+	}{
+		{"%v", m, "{a: 1, b: 2, c: 3}"},
+		{"%s", NewMap[string, string]().Set("k", "v"), "{k: v}"},
+		{"%q", NewMap[string, string]().Set("k", "v"), `{"k": "v"}`},
+		{"%d", NewMap[int, int]().Set(10, 1).Set(-2, 2), "{-2: 2, 10: 1}"},
+		{"%v", NewMap[string, int](), "{}"},
+		{"%v", NewMap[string, Map[int, bool]]().Set("x", NewMap[int, bool]().Set(1, true)), "{x: {1: true}}"},
+		{"%#v", m, `fn.NewMap[string, int]().Set("a", 1).Set("b", 2).Set("c", 3)`},
+		{"%#v", NewMap[string, any](), "fn.NewMap[string, any]()"},
+		{"%#v", NewMap[int, any]().Set(1, "x").Set(2, nil), `fn.NewMap[int, any]().Set(1, "x").Set(2, nil)`},
+		{"%#v", NewMap[int64, int]().Set(2, 20).Set(1, 10), "fn.NewMap[int64, int]().Set(1, 10).Set(2, 20)"},
+		{"%#v", NewMap[float32, string]().Set(2.5, "b").Set(-1, "a"), `fn.NewMap[float32, string]().Set(-1, "a").Set(2.5, "b")`},
+		{"%#v", NewMapWith[string, int](FoldHasher{}).Set("A", 1), `fn.NewMapWith[string, int](fn.FoldHasher{}).Set("A", 1)`},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprintf(tt.format, tt.value); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.format, tt.want, got)
+		}
+	}
+	if m.String() != "{a: 1, b: 2, c: 3}" || m.GoString() != fmt.Sprintf("%#v", m) {
+		t.Errorf("unexpected String %s or GoString %s", m.String(), m.GoString())
+	}
+
+	// Unordered keys are printed in iteration order
+	type point struct{ X, Y int }
+	pm := NewMap[point, int]().Set(point{1, 2}, 3)
+	if got := fmt.Sprintf("%+v", pm); got != "{{X:1 Y:2}: 3}" {
+		t.Errorf("expected %%+v to apply to the entries, got %s", got)
+	}
+}
+
+func TestOptionFormat(t *testing.T) {
+	tests := [][2]string{
+		{fmt.Sprint(Some(3)), "Some(3)"},
+		{fmt.Sprint(None[int]()), "None"},
+		{fmt.Sprintf("%q", Some("x")), `Some("x")`},
+		{fmt.Sprintf("%03d", Some(7)), "Some(007)"},
+		{fmt.Sprintf("%#v", Some(int64(3))), "fn.Some[int64](3)"},
+		{fmt.Sprintf("%#v", None[string]()), "fn.None[string]()"},
+		{Some(NewMap[string, int]()).String(), "Some({})"},
+		{None[any]().GoString(), "fn.None[any]()"},
+	}
+	for _, tt := range tests {
+		got, want := tt[0], tt[1]
+		if got != want {
+			t.Errorf("expected %s, got %s", want, got)
+		}
+	}
+}
+
+func TestResultFormat(t *testing.T) {
+	tests := [][2]string{
+		{fmt.Sprint(Ok(3)), "Ok(3)"},
+		{fmt.Sprint(Err[int](errors.New("boom"))), "Err(boom)"},
+		{fmt.Sprintf("%x", Ok(255)), "Ok(ff)"},
+		{fmt.Sprintf("%#v", Ok("a")), `fn.Ok[string]("a")`},
+		{Ok([]int{1}).GoString(), "fn.Ok[[]int]([]int{1})"},
+		{fmt.Sprintf("%+v", Ok(Some(1))), "Ok(Some(1))"},
+		{Err[int](errors.New("boom")).String(), "Err(boom)"},
+		{fmt.Sprintf("%s", Err[int](fs.ErrExist)), "Err(file already exists)"},
+		{fmt.Sprintf("%+v", Err[int](fs.ErrExist)), "Err(file already exists)"},
+		{fmt.Sprintf("%#v", Err[int](fs.ErrClosed)), fmt.Sprintf("fn.Err[int](%#v)", fs.ErrClosed)},
+	}
+	for _, tt := range tests {
+		got, want := tt[0], tt[1]
+		if got != want {
+			t.Errorf("expected %s, got %s", want, got)
+		}
+	}
+
+	pathErr := &fs.PathError{Op: "open", Path: "x", Err: fs.ErrNotExist}
+	err := fmt.Errorf("load: %w", errors.Join(pathErr, fs.ErrPermission))
+	got := fmt.Sprintf("%+v", Err[int](err))
+	lines := strings.Split(got, "\n")
+	want := []string{
+		"Err(load: open x: file does not exist",
+		"permission denied",
+		"\tcaused by *errors.joinError: open x: file does not exist",
+		"permission denied",
+		"\tcaused by *fs.PathError: open x: file does not exist",
+		"\tcaused by *errors.errorString: file does not exist",
+		"\tcaused by *errors.errorString: permission denied)",
+	}
+	if fmt.Sprint(lines) != fmt.Sprint(want) {
+		t.Errorf("expected the error chain\n%s\ngot\n%s", strings.Join(want, "\n"), got)
+	}
+}
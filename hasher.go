@@ -0,0 +1,348 @@
+package fn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Hasher defines how a [Map] hashes and compares its keys. Equal keys must
+// have equal hashes. Use a Hasher to key maps by types that are not
+// comparable (like slices), or by a notion of identity other than == (like
+// case-insensitive strings):
+//
+//	m := fn.NewMapWith[string, int](fn.FoldHasher{}).Set("Go", 1)
+//	m.Has("GO") // true
+//
+// Hashers must be comparable values, such as empty structs. Two maps are
+// considered to share a hashing scheme when their Hashers are ==; set
+// operations between maps with different schemes rehash the other map first.
+type Hasher[K any] interface {
+	Hash(k K) uint64
+	Equal(a, b K) bool
+}
+
+// defaultHasher is the hasher of a Map or Builder with a nil hasher, as the
+// zero Map has. It hashes keys of Go's basic types with maphash and compares
+// them with ==, without allocating. Other keys are hashed through an
+// interface, which allocates; constructors with comparable keys avoid that
+// by setting a [comparableHasher] instead, see [defaultHasherFor].
+type defaultHasher[K any] struct{}
+
+// requireComparable panics unless K can be used with the default hasher.
+// Map does not constrain its keys to be comparable, so that maps with a
+// custom [Hasher] can use slices and the like as keys; this catches a zero
+// Map of such a key type being used without one.
+func requireComparable[K any]() {
+	if t := reflect.TypeFor[K](); !t.Comparable() {
+		panic(fmt.Sprintf("fn: key type %v is not comparable; use NewMapWith with a Hasher", t))
+	}
+}
+
+func (defaultHasher[K]) Hash(k K) uint64 {
+	if h, ok := hashBasic(k); ok {
+		return h
+	}
+	requireComparable[K]()
+	return maphash.Comparable(seed, any(k))
+}
+
+func (defaultHasher[K]) Equal(a, b K) bool {
+	return any(a) == any(b)
+}
+
+func (defaultHasher[K]) isDefault() {}
+
+// hashBasic hashes k with maphash if K is one of Go's basic types, which
+// are hashed directly; boxing them in an interface would cost an allocation
+// per hash.
+func hashBasic[K any](k K) (uint64, bool) {
+	switch v := any(k).(type) {
+	case string:
+		return maphash.String(seed, v), true
+	case int:
+		return maphash.Comparable(seed, v), true
+	case int8:
+		return maphash.Comparable(seed, v), true
+	case int16:
+		return maphash.Comparable(seed, v), true
+	case int32:
+		return maphash.Comparable(seed, v), true
+	case int64:
+		return maphash.Comparable(seed, v), true
+	case uint:
+		return maphash.Comparable(seed, v), true
+	case uint8:
+		return maphash.Comparable(seed, v), true
+	case uint16:
+		return maphash.Comparable(seed, v), true
+	case uint32:
+		return maphash.Comparable(seed, v), true
+	case uint64:
+		return maphash.Comparable(seed, v), true
+	case uintptr:
+		return maphash.Comparable(seed, v), true
+	case float32:
+		return maphash.Comparable(seed, v), true
+	case float64:
+		return maphash.Comparable(seed, v), true
+	case bool:
+		return maphash.Comparable(seed, v), true
+	}
+	return 0, false
+}
+
+// comparableHasher hashes comparable keys with maphash and compares them
+// with ==. Knowing K is comparable, it needs neither a check nor an
+// interface, so it never allocates.
+type comparableHasher[K comparable] struct{}
+
+func (comparableHasher[K]) Hash(k K) uint64 {
+	return maphash.Comparable(seed, k)
+}
+
+func (comparableHasher[K]) Equal(a, b K) bool {
+	return a == b
+}
+
+func (comparableHasher[K]) isDefault() {}
+
+// defaultHasherFor returns the hasher that constructors without a Hasher
+// give Maps and Builders with comparable keys: nil, meaning [defaultHasher],
+// for basic key types, so that such Maps share a hashing scheme with the
+// zero Map, and a [comparableHasher] for the rest.
+func defaultHasherFor[K comparable]() Hasher[K] {
+	var zero K
+	if _, ok := hashBasic(zero); ok {
+		return nil
+	}
+	return comparableHasher[K]{}
+}
+
+// isDefaultHasher reports whether h is one of the hashers used when no
+// Hasher is given.
+func isDefaultHasher[K any](h Hasher[K]) bool {
+	_, ok := h.(interface{ isDefault() })
+	return h == nil || ok
+}
+
+// hasherOr returns h, or the default hasher if h is nil.
+func hasherOr[K any](h Hasher[K]) Hasher[K] {
+	if h == nil {
+		return defaultHasher[K]{}
+	}
+	return h
+}
+
+// sameHasher reports whether a and b hash and compare keys identically.
+func sameHasher[K any](a, b Hasher[K]) bool {
+	return hasherOr(a) == hasherOr(b)
+}
+
+// hashOf hashes k with hs, applying the package hash mask.
+func hashOf[K any](hs Hasher[K], k K) hashedKey {
+	return hs.Hash(k) & hashMask
+}
+
+// BytesHasher is a [Hasher] for byte slice keys, comparing them by content.
+// A Map does not copy its keys, so slices must not be modified after use as a key.
+type BytesHasher struct{}
+
+func (BytesHasher) Hash(k []byte) uint64 {
+	return maphash.Bytes(seed, k)
+}
+
+func (BytesHasher) Equal(a, b []byte) bool {
+	return bytes.Equal(a, b)
+}
+
+// FoldHasher is a [Hasher] for case-insensitive string keys. Keys are equal
+// under Unicode simple case folding, matching [strings.EqualFold].
+type FoldHasher struct{}
+
+func (FoldHasher) Hash(k string) uint64 {
+	var h maphash.Hash
+	h.SetSeed(seed)
+	var buf [utf8.UTFMax]byte
+	for _, r := range k {
+		n := utf8.EncodeRune(buf[:], foldRune(r))
+		h.Write(buf[:n])
+	}
+	return h.Sum64()
+}
+
+func (FoldHasher) Equal(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+// foldRune maps r to the smallest rune in its simple case folding orbit, so
+// all case variants of a rune map to the same representative.
+func foldRune(r rune) rune {
+	if r < utf8.RuneSelf {
+		// ASCII letters fold only to each other and, for k and s, to the
+		// Kelvin and long s signs above ASCII, so upper case is minimal.
+		if 'a' <= r && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		return r
+	}
+	least := r
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		least = min(least, f)
+	}
+	return least
+}
+
+// Hashable is implemented by key types that define their own identity, such
+// as structs identified by a subset of their fields.
+type Hashable[K any] interface {
+	Hash() uint64
+	Equal(other K) bool
+}
+
+// MethodHasher is a [Hasher] for keys implementing [Hashable], delegating to
+// the key's own Hash and Equal methods.
+type MethodHasher[K Hashable[K]] struct{}
+
+func (MethodHasher[K]) Hash(k K) uint64 {
+	return k.Hash()
+}
+
+func (MethodHasher[K]) Equal(a, b K) bool {
+	return a.Equal(b)
+}
+
+// StableHasher is a [Hasher] whose hashes depend only on the key and Seed,
+// never on the process, so a Map using it iterates in the same order in every
+// run and on every machine. Use it when iteration order is observable, such as
+// in golden files or caches shared between processes:
+//
+//	m := fn.NewStableMap[string, int](0)
+//
+// Keys are hashed with FNV-1a, starting from the FNV offset basis XORed with
+// Seed, followed by the murmur3 finalizer to spread the bits the trie uses.
+// Supported keys are strings, byte slices, booleans, integers, floats, and
+// arrays and structs built from them; hashing any other key panics.
+// Integers hash by value, independent of platform word size.
+type StableHasher[K any] struct {
+	Seed uint64
+}
+
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// stableState accumulates an FNV-1a hash.
+type stableState uint64
+
+func (s *stableState) write(b []byte) {
+	h := uint64(*s)
+	for _, c := range b {
+		h ^= uint64(c)
+		h *= fnvPrime
+	}
+	*s = stableState(h)
+}
+
+func (s *stableState) writeString(str string) {
+	h := uint64(*s)
+	for i := 0; i < len(str); i++ {
+		h ^= uint64(str[i])
+		h *= fnvPrime
+	}
+	*s = stableState(h)
+}
+
+func (s *stableState) writeUint64(v uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	s.write(buf[:])
+}
+
+// writeFloat hashes f so that values equal under == hash equally.
+func (s *stableState) writeFloat(f float64) {
+	if f == 0 {
+		f = 0 // fold -0 into +0
+	}
+	s.writeUint64(math.Float64bits(f))
+}
+
+// writeValue hashes a composite key's field or element. Strings are length
+// prefixed so that adjacent fields cannot run into each other.
+func (s *stableState) writeValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		s.writeUint64(uint64(v.Len()))
+		s.writeString(v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			s.write([]byte{1})
+		} else {
+			s.write([]byte{0})
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s.writeUint64(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s.writeUint64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		s.writeFloat(v.Float())
+	case reflect.Array:
+		for i := range v.Len() {
+			s.writeValue(v.Index(i))
+		}
+	case reflect.Struct:
+		for i := range v.NumField() {
+			s.writeValue(v.Field(i))
+		}
+	default:
+		panic(fmt.Sprintf("fn: StableHasher cannot hash %v", v.Type()))
+	}
+}
+
+func (h StableHasher[K]) Hash(k K) uint64 {
+	s := stableState(fnvOffset ^ h.Seed)
+	switch v := any(k).(type) {
+	case string:
+		s.writeString(v)
+	case []byte:
+		s.write(v)
+	case int:
+		s.writeUint64(uint64(v))
+	case int64:
+		s.writeUint64(uint64(v))
+	case uint64:
+		s.writeUint64(v)
+	default:
+		rv := reflect.ValueOf(&k).Elem()
+		if rv.Kind() == reflect.String {
+			s.writeString(rv.String())
+		} else {
+			s.writeValue(rv)
+		}
+	}
+	return mix(uint64(s))
+}
+
+func (StableHasher[K]) Equal(a, b K) bool {
+	if ab, ok := any(a).([]byte); ok {
+		return bytes.Equal(ab, any(b).([]byte))
+	}
+	return any(a) == any(b)
+}
+
+// mix is the murmur3 64-bit finalizer.
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
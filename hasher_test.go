@@ -0,0 +1,298 @@
+package fn
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"hash/maphash"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestBytesHasher(t *testing.T) {
+	m := NewMapWith[[]byte, int](BytesHasher{})
+	m = m.Set([]byte("a"), 1).Set([]byte("b"), 2)
+	m = m.Set([]byte("a"), 10)
+
+	if m.Len() != 2 {
+		t.Errorf("expected len 2, got %d", m.Len())
+	}
+	if v, ok := m.Get([]byte("a")); !ok || v != 10 {
+		t.Errorf("expected a=10, got %d, %v", v, ok)
+	}
+	if m.Has([]byte("c")) {
+		t.Error("expected 'c' to not be found")
+	}
+
+	m = m.Delete([]byte("b"))
+	if m.Len() != 1 || m.Has([]byte("b")) {
+		t.Error("expected 'b' to be deleted")
+	}
+}
+
+func TestUncomparableKeys(t *testing.T) {
+	// Constructors require comparable keys at compile time; only the zero
+	// value can be used with a key type that the default hasher cannot hash
+	tests := map[string]func(){
+		"zero Map": func() { Map[[]byte, int]{}.Set([]byte("a"), 1) },
+		"zero Set": func() { Set[[]byte]{}.Add([]byte("a")) },
+	}
+	for name, f := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				want := "fn: key type []uint8 is not comparable; use NewMapWith with a Hasher"
+				if r := recover(); r != want {
+					t.Errorf("expected panic %q, got %v", want, r)
+				}
+			}()
+			f()
+		})
+	}
+
+	// Interface keys are allowed, as long as their dynamic types are comparable
+	m := NewMap[any, int]().Set("a", 1).Set(2, 2)
+	if m.Len() != 2 {
+		t.Errorf("expected len 2, got %d", m.Len())
+	}
+}
+
+func TestDefaultHasherAllocs(t *testing.T) {
+	type pt struct{ X, Y int }
+	m := NewMap[pt, int]().Set(pt{1, 2}, 3)
+	if n := testing.AllocsPerRun(100, func() { m.Get(pt{1, 2}) }); n != 0 {
+		t.Errorf("expected Get with struct keys not to allocate, got %v allocs", n)
+	}
+	s := NewSet[string]().Add("a")
+	if n := testing.AllocsPerRun(100, func() { s.Contains("a") }); n != 0 {
+		t.Errorf("expected Contains with string elements not to allocate, got %v allocs", n)
+	}
+
+	// Maps with basic keys share the zero Map's hashing scheme
+	if !sameHasher(NewMap[int, int]().hasher, Map[int, int]{}.hasher) {
+		t.Error("expected NewMap and the zero Map to hash int keys alike")
+	}
+}
+
+func TestFoldHasher(t *testing.T) {
+	m := NewMapWith[string, int](FoldHasher{}).Set("Go", 1)
+
+	for _, k := range []string{"go", "GO", "gO", "Go"} {
+		if v, ok := m.Get(k); !ok || v != 1 {
+			t.Errorf("expected %q to find 1, got %d, %v", k, v, ok)
+		}
+	}
+
+	m = m.Set("GO", 2)
+	if m.Len() != 1 {
+		t.Errorf("expected case variants to share an entry, got len %d", m.Len())
+	}
+
+	// Kelvin sign folds to k, and sigma variants fold together
+	m = m.Set("Kelvin", 3)
+	if v, _ := m.Get("kelvin"); v != 3 {
+		t.Errorf("expected kelvin=3, got %d", v)
+	}
+	m = m.Set("ΣΑΣ", 4)
+	if v, _ := m.Get("σας"); v != 4 {
+		t.Errorf("expected σας=4, got %d", v)
+	}
+
+	if m.Has("rust") {
+		t.Error("expected 'rust' to not be found")
+	}
+}
+
+type point struct {
+	x, y  int
+	label string // not part of identity
+}
+
+func (p point) Hash() uint64 {
+	return maphash.Comparable(seed, [2]int{p.x, p.y})
+}
+
+func (p point) Equal(o point) bool {
+	return p.x == o.x && p.y == o.y
+}
+
+func TestMethodHasher(t *testing.T) {
+	m := NewMapWith[point, string](MethodHasher[point]{})
+	m = m.Set(point{1, 2, "first"}, "a")
+	m = m.Set(point{1, 2, "second"}, "b")
+
+	if m.Len() != 1 {
+		t.Errorf("expected points equal by coordinates to share an entry, got len %d", m.Len())
+	}
+	if v, _ := m.Get(point{x: 1, y: 2}); v != "b" {
+		t.Errorf("expected b, got %q", v)
+	}
+}
+
+func TestHasherCollisions(t *testing.T) {
+	forceCollisions(t, 0)
+
+	m := NewMapWith[string, int](FoldHasher{})
+	for i := range 10 {
+		m = m.Set(fmt.Sprintf("KEY%d", i), i)
+	}
+	for i := range 10 {
+		if v, ok := m.Get(fmt.Sprintf("key%d", i)); !ok || v != i {
+			t.Errorf("key%d: expected %d, got %d, %v", i, i, v, ok)
+		}
+	}
+	m = m.Delete("Key3")
+	if m.Len() != 9 || m.Has("KEY3") {
+		t.Error("expected KEY3 to be deleted through a case variant")
+	}
+}
+
+func TestHasherPreserved(t *testing.T) {
+	m := NewMapWith[string, int](FoldHasher{}).Set("a", 1).Set("b", 2)
+
+	derived := map[string]Map[string, int]{
+		"Set":       m.Set("c", 3),
+		"Delete":    m.Delete("b"),
+		"Filter":    m.Filter(func(string, int) bool { return true }),
+		"Union":     m.Union(NewMapWith[string, int](FoldHasher{}).Set("c", 3)),
+		"Batch":     m.Batch(func(b *Builder[string, int]) { b.Set("c", 3) }),
+		"ToBuilder": m.ToBuilder().Build(),
+	}
+	for name, d := range derived {
+		if !d.Has("A") {
+			t.Errorf("%s: expected case-insensitive lookup to survive", name)
+		}
+	}
+
+	b := NewBuilderWith[string, int](FoldHasher{})
+	b.Set("X", 1).Set("x", 2)
+	if b.Len() != 1 {
+		t.Errorf("expected builder to fold keys, got len %d", b.Len())
+	}
+	if v, _ := b.Build().Get("X"); v != 2 {
+		t.Errorf("expected X=2, got %d", v)
+	}
+}
+
+func TestSetOperationsMixedHashers(t *testing.T) {
+	folded := NewMapWith[string, int](FoldHasher{}).Set("A", 1).Set("B", 2)
+	plain := NewMap[string, int]().Set("b", 20).Set("c", 3)
+
+	union := folded.Union(plain)
+	if union.Len() != 3 {
+		t.Errorf("expected len 3, got %d", union.Len())
+	}
+	if v, _ := union.Get("B"); v != 20 {
+		t.Errorf("expected B=20, got %d", v)
+	}
+
+	inter := folded.Intersection(plain)
+	if inter.Len() != 1 || !inter.Has("b") {
+		t.Errorf("expected intersection {B}, got len %d", inter.Len())
+	}
+}
+
+func TestMapJSONKeepsHasher(t *testing.T) {
+	m := NewMapWith[string, int](FoldHasher{})
+	if err := json.Unmarshal([]byte(`{"Key": 1}`), &m); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if !m.Has("KEY") {
+		t.Error("expected unmarshaled map to keep its hasher")
+	}
+
+	if _, err := json.Marshal(NewMapWith[[]byte, int](BytesHasher{}).Set([]byte("a"), 1)); err == nil {
+		t.Error("expected an error marshaling non-comparable keys")
+	}
+}
+
+func TestStableHasherFNV(t *testing.T) {
+	for _, k := range []string{"", "a", "foobar", "hello, world"} {
+		f := fnv.New64a()
+		f.Write([]byte(k))
+		if got, want := (StableHasher[string]{}).Hash(k), mix(f.Sum64()); got != want {
+			t.Errorf("Hash(%q) = %#x, expected %#x", k, got, want)
+		}
+	}
+
+	// Pinned values guard against accidental changes to the hash function
+	if h := (StableHasher[string]{}).Hash("a"); h != 0x82a2a958a9bece5b {
+		t.Errorf("Hash(\"a\") = %#x, expected 0x82a2a958a9bece5b", h)
+	}
+	if h := (StableHasher[int]{}).Hash(1); h != 0x4a3a3a4ba6523826 {
+		t.Errorf("Hash(1) = %#x, expected 0x4a3a3a4ba6523826", h)
+	}
+}
+
+func TestStableHasherKinds(t *testing.T) {
+	type id string
+	type pair struct {
+		a string
+		b int32
+	}
+
+	if (StableHasher[id]{}).Hash("x") != (StableHasher[string]{}).Hash("x") {
+		t.Error("named string types should hash like strings")
+	}
+	if (StableHasher[int32]{}).Hash(-5) != (StableHasher[int64]{}).Hash(-5) {
+		t.Error("integers should hash by value regardless of width")
+	}
+	if (StableHasher[[]byte]{}).Hash([]byte("x")) != (StableHasher[string]{}).Hash("x") {
+		t.Error("byte slices should hash like strings")
+	}
+	if (StableHasher[float64]{}).Hash(0) != (StableHasher[float64]{}).Hash(math.Copysign(0, -1)) {
+		t.Error("zero floats should hash equally")
+	}
+
+	ph := StableHasher[pair]{}
+	if ph.Hash(pair{"ab", 1}) == ph.Hash(pair{"a", 1}) {
+		t.Error("distinct structs should hash differently")
+	}
+	if ph.Hash(pair{"ab", 1}) != ph.Hash(pair{"ab", 1}) {
+		t.Error("equal structs should hash equally")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected hashing a pointer key to panic")
+		}
+	}()
+	StableHasher[*int]{}.Hash(new(int))
+}
+
+func TestStableMapOrder(t *testing.T) {
+	keys := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+
+	build := func(seed uint64, order []string) Map[string, int] {
+		m := NewStableMap[string, int](seed)
+		for _, k := range order {
+			m = m.Set(k, 0)
+		}
+		return m
+	}
+
+	// The order is pinned, so it holds across processes and machines
+	want := []string{"b", "f", "a", "d", "g", "c", "h", "i", "e", "j"}
+	if got := build(0, keys).Keys(); !slices.Equal(got, want) {
+		t.Errorf("expected order %q, got %q", want, got)
+	}
+
+	// Insertion order does not matter
+	reversed := slices.Clone(keys)
+	slices.Reverse(reversed)
+	if got := build(0, reversed).Keys(); !slices.Equal(got, want) {
+		t.Errorf("expected order %q after reversed inserts, got %q", want, got)
+	}
+	bld := NewBuilderWith[string, int](StableHasher[string]{})
+	for _, k := range reversed {
+		bld.Set(k, 0)
+	}
+	if got := bld.Build().Keys(); !slices.Equal(got, want) {
+		t.Errorf("expected order %q from a Builder, got %q", want, got)
+	}
+
+	// The seed changes the order
+	if got := build(7, keys).Keys(); slices.Equal(got, want) {
+		t.Errorf("expected seed 7 to give a different order than seed 0, got %q", got)
+	}
+}
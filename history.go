@@ -0,0 +1,216 @@
+package fn
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"slices"
+)
+
+// ErrUnknownVersion is returned when a [History] is asked for a version it
+// never had or no longer retains.
+var ErrUnknownVersion = errors.New("fn: unknown version")
+
+// Version identifies a Map committed to a [History]. Versions are numbered
+// from 0, the Map the History was created with, and are never reused.
+type Version int
+
+// History records successive versions of a Map, with undo and redo like an
+// editor's. Since each version is an immutable Map, versions share every
+// subtree they have in common, and keeping one costs only the nodes its
+// commit changed.
+//
+// History is linear: committing after an undo or checkout discards the
+// versions that could have been redone, along with their tags. A History is
+// not safe for concurrent use; wrap it in a lock, or keep the current Map in
+// a [Ref], if several goroutines share it.
+type History[K any, V Val] struct {
+	versions []snapshot[K, V] // retained versions, oldest first
+	cur      int              // index of the current version in versions
+	next     Version          // number of the next commit
+	keep     int              // maximum untagged versions retained, or 0 for all
+	tags     map[string]Version
+}
+
+// snapshot is a Map committed to a History.
+type snapshot[K any, V Val] struct {
+	v Version
+	m Map[K, V]
+}
+
+// NewHistory creates a History whose version 0 is m. If keep is positive, at
+// most keep versions are retained, not counting tagged ones: each commit
+// beyond that drops the oldest untagged version, which can then no longer
+// be undone to or checked out.
+func NewHistory[K any, V Val](m Map[K, V], keep int) *History[K, V] {
+	return &History[K, V]{
+		versions: []snapshot[K, V]{{v: 0, m: m}},
+		next:     1,
+		keep:     keep,
+		tags:     make(map[string]Version),
+	}
+}
+
+// Current returns the current Map.
+func (h *History[K, V]) Current() Map[K, V] {
+	return h.versions[h.cur].m
+}
+
+// Version returns the current version.
+func (h *History[K, V]) Version() Version {
+	return h.versions[h.cur].v
+}
+
+// Commit records m as a new version following the current one, makes it
+// current, and returns its version. Versions that could have been redone
+// are discarded.
+func (h *History[K, V]) Commit(m Map[K, V]) Version {
+	for _, s := range h.versions[h.cur+1:] {
+		h.untag(s.v)
+	}
+	clear(h.versions[h.cur+1:]) // release the discarded Maps
+	v := h.next
+	h.next++
+	h.versions = append(h.versions[:h.cur+1], snapshot[K, V]{v: v, m: m})
+	h.cur = len(h.versions) - 1
+	h.trim()
+	return v
+}
+
+// Update commits f applied to the current Map and returns the new version.
+func (h *History[K, V]) Update(f func(Map[K, V]) Map[K, V]) Version {
+	return h.Commit(f(h.Current()))
+}
+
+// trim drops the oldest untagged versions beyond the retention limit. The
+// current version is never dropped.
+func (h *History[K, V]) trim() {
+	if h.keep <= 0 {
+		return
+	}
+	pinned := make(map[Version]bool, len(h.tags))
+	for _, v := range h.tags {
+		pinned[v] = true
+	}
+	excess := len(h.versions) - len(pinned) - h.keep
+	if excess <= 0 {
+		return
+	}
+	cur := h.Version()
+	h.versions = slices.DeleteFunc(h.versions, func(s snapshot[K, V]) bool {
+		if excess == 0 || s.v == cur || pinned[s.v] {
+			return false
+		}
+		excess--
+		return true
+	})
+	h.cur = h.index(cur)
+}
+
+// Undo makes the version before the current one current, and reports
+// whether there was one.
+func (h *History[K, V]) Undo() bool {
+	if h.cur == 0 {
+		return false
+	}
+	h.cur--
+	return true
+}
+
+// Redo makes the version after the current one current, and reports whether
+// there was one.
+func (h *History[K, V]) Redo() bool {
+	if h.cur == len(h.versions)-1 {
+		return false
+	}
+	h.cur++
+	return true
+}
+
+// Checkout makes version v current, as if by undoing or redoing to it.
+func (h *History[K, V]) Checkout(v Version) error {
+	i := h.index(v)
+	if i < 0 {
+		return fmt.Errorf("%w %d", ErrUnknownVersion, v)
+	}
+	h.cur = i
+	return nil
+}
+
+// At returns the Map of version v, if it is retained.
+func (h *History[K, V]) At(v Version) (Map[K, V], bool) {
+	if i := h.index(v); i >= 0 {
+		return h.versions[i].m, true
+	}
+	return Map[K, V]{}, false
+}
+
+// Versions returns an iterator over the retained versions and their Maps,
+// oldest first.
+func (h *History[K, V]) Versions() iter.Seq2[Version, Map[K, V]] {
+	return func(yield func(Version, Map[K, V]) bool) {
+		for _, s := range h.versions {
+			if !yield(s.v, s.m) {
+				return
+			}
+		}
+	}
+}
+
+// Len returns the number of retained versions.
+func (h *History[K, V]) Len() int {
+	return len(h.versions)
+}
+
+// Diff returns the changes that turn version from into version to, as
+// [Map.Diff] does.
+func (h *History[K, V]) Diff(from, to Version) (iter.Seq[Change[K, V]], error) {
+	a, ok := h.At(from)
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownVersion, from)
+	}
+	b, ok := h.At(to)
+	if !ok {
+		return nil, fmt.Errorf("%w %d", ErrUnknownVersion, to)
+	}
+	return a.Diff(b), nil
+}
+
+// Tag names the current version, moving the name if it was already in use.
+// Tagged versions are kept regardless of the retention limit until their
+// tag is removed or they are discarded by a commit after an undo.
+func (h *History[K, V]) Tag(name string) {
+	h.tags[name] = h.Version()
+}
+
+// Untag removes a tag. The version it named becomes subject to the retention
+// limit again.
+func (h *History[K, V]) Untag(name string) {
+	delete(h.tags, name)
+}
+
+// Tagged returns the version named name, if any.
+func (h *History[K, V]) Tagged(name string) (Version, bool) {
+	v, ok := h.tags[name]
+	return v, ok
+}
+
+// index returns the position of version v in versions, or -1.
+func (h *History[K, V]) index(v Version) int {
+	i, ok := slices.BinarySearchFunc(h.versions, v, func(s snapshot[K, V], v Version) int {
+		return int(s.v - v)
+	})
+	if !ok {
+		return -1
+	}
+	return i
+}
+
+// untag removes every tag naming v.
+func (h *History[K, V]) untag(v Version) {
+	for name, t := range h.tags {
+		if t == v {
+			delete(h.tags, name)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package fn
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// versions returns the retained versions of h.
+func versions[K any, V Val](h *History[K, V]) []Version {
+	return slices.Collect(Keys(h.Versions()))
+}
+
+func TestHistoryUndoRedo(t *testing.T) {
+	h := NewHistory(NewMap[string, int](), 0)
+	v1 := h.Commit(h.Current().Set("a", 1))
+	v2 := h.Update(func(m Map[string, int]) Map[string, int] { return m.Set("b", 2) })
+	if v1 != 1 || v2 != 2 || h.Version() != v2 || h.Current().Len() != 2 {
+		t.Fatalf("expected version 2 with 2 keys, got %d with %d", h.Version(), h.Current().Len())
+	}
+
+	if !h.Undo() || h.Version() != v1 || h.Current().Has("b") {
+		t.Error("expected undo to restore version 1")
+	}
+	if !h.Undo() || h.Current().Len() != 0 || h.Undo() {
+		t.Error("expected undo to stop at version 0")
+	}
+	if !h.Redo() || !h.Redo() || h.Version() != v2 || h.Redo() {
+		t.Error("expected redo to stop at version 2")
+	}
+
+	// Committing after an undo discards what could have been redone
+	h.Undo()
+	v3 := h.Commit(h.Current().Set("c", 3))
+	if v3 != 3 || h.Redo() {
+		t.Errorf("expected a new version 3 with nothing to redo, got %d", v3)
+	}
+	if got := versions(h); !slices.Equal(got, []Version{0, 1, 3}) {
+		t.Errorf("expected versions [0 1 3], got %v", got)
+	}
+	if _, ok := h.At(v2); ok {
+		t.Error("expected version 2 to be discarded")
+	}
+}
+
+func TestHistoryCheckout(t *testing.T) {
+	h := NewHistory(NewMap[int, int](), 0)
+	for i := range 5 {
+		h.Commit(h.Current().Set(i, i))
+	}
+	if err := h.Checkout(2); err != nil || h.Current().Len() != 2 {
+		t.Errorf("expected version 2 with 2 keys, got %v", err)
+	}
+	if !h.Redo() || h.Version() != 3 {
+		t.Error("expected redo to follow a checkout")
+	}
+	if err := h.Checkout(9); !errors.Is(err, ErrUnknownVersion) || h.Version() != 3 {
+		t.Errorf("expected ErrUnknownVersion and no change, got %v", err)
+	}
+	if m, ok := h.At(4); !ok || m.Len() != 4 {
+		t.Error("expected At to return version 4")
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	h := NewHistory(NewMap[int, int](), 3)
+	h.Commit(h.Current().Set(1, 1))
+	h.Tag("first")
+	for i := 2; i <= 6; i++ {
+		h.Commit(h.Current().Set(i, i))
+	}
+	// Three untagged versions, plus the tagged one
+	if got := versions(h); !slices.Equal(got, []Version{1, 4, 5, 6}) {
+		t.Errorf("expected versions [1 4 5 6], got %v", got)
+	}
+	if v, ok := h.Tagged("first"); !ok || v != 1 {
+		t.Errorf("expected first to name version 1, got %d", v)
+	}
+	if err := h.Checkout(2); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("expected version 2 to be dropped, got %v", err)
+	}
+
+	// Undo steps over dropped versions
+	for h.Undo() {
+	}
+	if h.Version() != 1 || h.Current().Len() != 1 {
+		t.Errorf("expected to undo back to version 1, got %d", h.Version())
+	}
+
+	// Untagged versions are dropped by the next commit
+	h.Redo()
+	h.Redo()
+	h.Redo()
+	h.Untag("first")
+	h.Commit(h.Current().Set(7, 7))
+	if got := versions(h); !slices.Equal(got, []Version{5, 6, 7}) {
+		t.Errorf("expected versions [5 6 7], got %v", got)
+	}
+}
+
+func TestHistoryTags(t *testing.T) {
+	h := NewHistory(NewMap[string, int](), 0)
+	h.Commit(h.Current().Set("a", 1))
+	h.Tag("release")
+	h.Commit(h.Current().Set("a", 2))
+	h.Tag("draft")
+	if v, _ := h.Tagged("release"); v != 1 {
+		t.Errorf("expected release to name version 1, got %d", v)
+	}
+
+	h.Tag("release")
+	if v, _ := h.Tagged("release"); v != 2 {
+		t.Errorf("expected Tag to move release to version 2, got %d", v)
+	}
+
+	// Tags of discarded versions go with them
+	h.Undo()
+	h.Commit(h.Current().Set("b", 1))
+	if _, ok := h.Tagged("draft"); ok {
+		t.Error("expected the tag of a discarded version to be removed")
+	}
+	if _, ok := h.Tagged("missing"); ok {
+		t.Error("expected no version for a missing tag")
+	}
+}
+
+func TestHistoryDiff(t *testing.T) {
+	h := NewHistory(MapFromPairs[string, int]("a", 1, "b", 2), 0)
+	h.Commit(h.Current().Set("a", 10).Delete("b").Set("c", 3))
+
+	changes, err := h.Diff(0, 1)
+	if err != nil {
+		t.Fatalf("Diff error: %v", err)
+	}
+	got := make(map[string]ChangeKind)
+	for c := range changes {
+		got[c.Key] = c.Kind
+	}
+	if got["a"] != Changed || got["b"] != Removed || got["c"] != Added || len(got) != 3 {
+		t.Errorf("unexpected changes %v", got)
+	}
+
+	if _, err := h.Diff(0, 5); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("expected ErrUnknownVersion, got %v", err)
+	}
+}
@@ -14,7 +14,7 @@ import (
 )
 
 // Iterable is the shared interface that connects container types to the
-// iterator combinator pipeline. Both [Result] and [Option] satisfy Iterable,
+// iterator combinator pipeline. [Result], [Option], [Vec] and [List] satisfy Iterable,
 // so they can be passed directly to [Iter] and from there into [Apply], [Filter],
 // [Chain], [Reduce], and any other function that accepts an iter.Seq.
 type Iterable[T any] interface {
@@ -30,6 +30,51 @@ func Iter[T any](x Iterable[T]) iter.Seq[T] {
 	return x.Iter()
 }
 
+// Iterable2 is the key-value counterpart of [Iterable], implemented by
+// containers that naturally yield pairs: [Map] yields its entries and [Vec]
+// yields index-value pairs. Pass one to [Iter2] to feed it into any function
+// that accepts an iter.Seq2, such as [Keys] or [Values].
+type Iterable2[K, V any] interface {
+	All() iter.Seq2[K, V]
+}
+
+// Iter2 extracts an iter.Seq2 from any [Iterable2] container, mirroring [Iter]
+// for key-value containers:
+//
+//	for k, v := range fn.Iter2(m) { ... }
+func Iter2[K, V any](x Iterable2[K, V]) iter.Seq2[K, V] {
+	return x.All()
+}
+
+// Keys projects an iter.Seq2 onto its first element, turning a key-value
+// sequence into a plain iterator that composes with [Apply], [Filter] and
+// the rest of the pipeline:
+//
+//	names := fn.Collect(fn.Keys(fn.Iter2(m)))
+func Keys[K, V any](in iter.Seq2[K, V]) iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range in {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// Values projects an iter.Seq2 onto its second element. It is the
+// counterpart of [Keys]:
+//
+//	total := fn.Sum(fn.Values(fn.Iter2(m)))
+func Values[K, V any](in iter.Seq2[K, V]) iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range in {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
+
 type unwrappable[T any] interface {
 	unwrap() (T, bool)
 }
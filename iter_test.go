@@ -459,6 +459,50 @@ func TestOptionUnwrapOrZeroValue(t *testing.T) {
 	check.Eq(UnwrapOr(None[int](), 0), 0)
 }
 
+// --- Iter2 / Keys / Values ---
+
+func TestIter2Vec(t *testing.T) {
+	v := Vec[string]{"a", "b", "c"}
+	idx := Collect(Keys(Iter2(v)))
+	check.SliceEq(idx, Vec[int]{0, 1, 2}, "unexpected indexes")
+	vals := Collect(Values(Iter2(v)))
+	check.SliceEq(vals, v, "unexpected values")
+}
+
+func TestIter2Map(t *testing.T) {
+	m := MapFromPairs[string, int]("a", 1, "b", 2, "c", 3)
+	check.Eq(Sum(Values(Iter2(m))), 6)
+	check.Eq(Len(Keys(Iter2(m))), 3)
+}
+
+func TestKeysEarlyBreak(t *testing.T) {
+	count := 0
+	for range Keys(Enumerate(Range(0, 100))) {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	check.Eq(count, 3)
+}
+
+func TestValuesEarlyBreak(t *testing.T) {
+	count := 0
+	for range Values(Enumerate(Range(0, 100))) {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	check.Eq(count, 3)
+}
+
+func TestListIterPipeline(t *testing.T) {
+	l := NewList(3).Prepend(2).Prepend(1)
+	doubled := Apply(Iter(l), func(i int) int { return i * 2 })
+	check.Eq(Sum(doubled), 12)
+}
+
 // --- Composition ---
 
 func TestFilterApplyReduceComposition(t *testing.T) {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"iter"
 )
 
 var IndexOutOfRange = errors.New("index out of range")
@@ -122,6 +123,20 @@ func (l *List[T]) Each(f func(i T)) {
 	l.Next().Each(f)
 }
 
+// Iter implements [Iterable], yielding each value from the head of the list
+// to its end. A nil list yields nothing.
+func (l *List[T]) Iter() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		for y := l; y != nil; y = y.next {
+			if !yield(y.val) {
+				return
+			}
+		}
+	}
+}
+
+var _ Iterable[int] = NewList(0)
+
 func (l *List[T]) Filter(f func(*List[T]) bool) *List[T] {
 	if l == nil {
 		return nil
@@ -57,3 +57,29 @@ func TestListPrepend(t *testing.T) {
 		t.Errorf("Expcted 2 got %d", i)
 	}
 }
+
+func TestListIter(t *testing.T) {
+	l := NewList(3).Prepend(2).Prepend(1)
+
+	var got []int
+	for v := range l.Iter() {
+		got = append(got, v)
+	}
+	if len(got) != 3 || got[0] != 1 || got[1] != 2 || got[2] != 3 {
+		t.Errorf("Expected [1 2 3] got %v", got)
+	}
+
+	var empty *List[int]
+	for range empty.Iter() {
+		t.Error("Expected nil list to yield nothing")
+	}
+
+	count := 0
+	for range l.Iter() {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("Expected iteration to stop after 1 got %d", count)
+	}
+}
@@ -0,0 +1,269 @@
+package fn
+
+import (
+	"iter"
+	"slices"
+)
+
+// MultiMap is an immutable map from each key to a set of values, for
+// one-to-many relationships such as an index from tags to resources. It is a
+// [Map] of [Set]s, so adding or removing a pair copies only the path to the
+// key and the path to the value within its set. A key is present while it has
+// at least one value. All operations return a new MultiMap, leaving the
+// original unchanged.
+//
+// See [ListMultiMap] for a variant that keeps each key's values in insertion
+// order and allows duplicates.
+type MultiMap[K any, V any] struct {
+	m   Map[K, Set[V]]
+	vh  Hasher[V] // hasher for new value sets, or nil for the default
+	len int       // number of pairs
+}
+
+// NewMultiMap creates an empty MultiMap that hashes keys and values like
+// [NewMap]. Use [NewMultiMapWith] for other key or value types.
+func NewMultiMap[K, V comparable]() MultiMap[K, V] {
+	return MultiMap[K, V]{m: NewMap[K, Set[V]](), vh: defaultHasherFor[V]()}
+}
+
+// NewMultiMapWith creates an empty MultiMap that hashes and compares keys
+// with kh and values with vh.
+func NewMultiMapWith[K any, V any](kh Hasher[K], vh Hasher[V]) MultiMap[K, V] {
+	return MultiMap[K, V]{m: NewMapWith[K, Set[V]](kh), vh: vh}
+}
+
+// Add returns a new MultiMap with v added to the values of k. Returns the
+// same MultiMap if k already has v.
+func (mm MultiMap[K, V]) Add(k K, v V) MultiMap[K, V] {
+	added := false
+	m := mm.m.Update(k, func(o Option[Set[V]]) Option[Set[V]] {
+		s, ok := o.unwrap()
+		if !ok {
+			s = NewSetWith(mm.vh)
+		}
+		if s.Contains(v) {
+			return o
+		}
+		added = true
+		return Some(s.Add(v))
+	})
+	if !added {
+		return mm
+	}
+	return MultiMap[K, V]{m: m, vh: mm.vh, len: mm.len + 1}
+}
+
+// Remove returns a new MultiMap without v among the values of k, removing k
+// if it has no values left. Returns the same MultiMap if k does not have v.
+func (mm MultiMap[K, V]) Remove(k K, v V) MultiMap[K, V] {
+	s, ok := mm.m.Get(k)
+	if !ok || !s.Contains(v) {
+		return mm
+	}
+	var m Map[K, Set[V]]
+	if s.Len() == 1 {
+		m = mm.m.Delete(k)
+	} else {
+		m = mm.m.Set(k, s.Remove(v))
+	}
+	return MultiMap[K, V]{m: m, vh: mm.vh, len: mm.len - 1}
+}
+
+// RemoveAll returns a new MultiMap without k and any of its values.
+func (mm MultiMap[K, V]) RemoveAll(k K) MultiMap[K, V] {
+	s, ok := mm.m.Get(k)
+	if !ok {
+		return mm
+	}
+	return MultiMap[K, V]{m: mm.m.Delete(k), vh: mm.vh, len: mm.len - s.Len()}
+}
+
+// Get returns an iterator over the values of k, which yields nothing if k
+// is absent.
+func (mm MultiMap[K, V]) Get(k K) iter.Seq[V] {
+	s, _ := mm.m.Get(k)
+	return s.All()
+}
+
+// Values returns the values of k as a Set, which is empty if k is absent.
+func (mm MultiMap[K, V]) Values(k K) Set[V] {
+	s, ok := mm.m.Get(k)
+	if !ok {
+		return NewSetWith(mm.vh)
+	}
+	return s
+}
+
+// Has returns true if k has at least one value.
+func (mm MultiMap[K, V]) Has(k K) bool {
+	return mm.m.Has(k)
+}
+
+// Contains returns true if k has the value v.
+func (mm MultiMap[K, V]) Contains(k K, v V) bool {
+	s, ok := mm.m.Get(k)
+	return ok && s.Contains(v)
+}
+
+// Count returns the number of values of k.
+func (mm MultiMap[K, V]) Count(k K) int {
+	s, _ := mm.m.Get(k)
+	return s.Len()
+}
+
+// Len returns the number of key-value pairs in the MultiMap.
+func (mm MultiMap[K, V]) Len() int {
+	return mm.len
+}
+
+// KeyLen returns the number of distinct keys in the MultiMap.
+func (mm MultiMap[K, V]) KeyLen() int {
+	return mm.m.Len()
+}
+
+// KeysSeq returns an iterator over the distinct keys of the MultiMap.
+func (mm MultiMap[K, V]) KeysSeq() iter.Seq[K] {
+	return mm.m.KeysSeq()
+}
+
+// All returns an iterator over every key-value pair, yielding a key once for
+// each of its values. It implements [Iterable2].
+func (mm MultiMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		mm.m.ForEach(func(k K, s Set[V]) bool {
+			for v := range s.All() {
+				if !yield(k, v) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
+
+// Equal returns true if both MultiMaps have the same keys with the same
+// values.
+func (mm MultiMap[K, V]) Equal(other MultiMap[K, V]) bool {
+	return mm.len == other.len && mm.m.EqualFunc(other.m, Set[V].Equal)
+}
+
+// ListMultiMap is an immutable map from each key to a list of values, kept
+// in the order they were added. Unlike [MultiMap], a key may hold the same
+// value more than once, and values need not be hashable. Each key's list is
+// copied when it changes, so it suits keys with modest numbers of values.
+// A key is present while it has at least one value. All operations return a
+// new ListMultiMap, leaving the original unchanged.
+type ListMultiMap[K any, V Val] struct {
+	m   Map[K, Vec[V]]
+	len int // number of pairs
+}
+
+// NewListMultiMap creates an empty ListMultiMap that hashes keys like
+// [NewMap]. Use [NewListMultiMapWith] for other key types.
+func NewListMultiMap[K comparable, V Val]() ListMultiMap[K, V] {
+	return ListMultiMap[K, V]{m: NewMap[K, Vec[V]]()}
+}
+
+// NewListMultiMapWith creates an empty ListMultiMap that hashes and compares
+// keys with h.
+func NewListMultiMapWith[K any, V Val](h Hasher[K]) ListMultiMap[K, V] {
+	return ListMultiMap[K, V]{m: NewMapWith[K, Vec[V]](h)}
+}
+
+// Add returns a new ListMultiMap with v appended to the values of k.
+func (mm ListMultiMap[K, V]) Add(k K, v V) ListMultiMap[K, V] {
+	m := mm.m.Update(k, func(o Option[Vec[V]]) Option[Vec[V]] {
+		vs, _ := o.unwrap()
+		// Never append in place: the backing array may be shared
+		return Some(append(vs[:len(vs):len(vs)], v))
+	})
+	return ListMultiMap[K, V]{m: m, len: mm.len + 1}
+}
+
+// Remove returns a new ListMultiMap without the first occurrence of v among
+// the values of k, removing k if it has no values left. Values are compared
+// as by [Map.Equal]. Returns the same ListMultiMap if k does not have v.
+func (mm ListMultiMap[K, V]) Remove(k K, v V) ListMultiMap[K, V] {
+	vs, _ := mm.m.Get(k)
+	i := slices.IndexFunc(vs, func(x V) bool { return valuesEqual(x, v) })
+	if i < 0 {
+		return mm
+	}
+	var m Map[K, Vec[V]]
+	if len(vs) == 1 {
+		m = mm.m.Delete(k)
+	} else {
+		m = mm.m.Set(k, removeAt(vs, i))
+	}
+	return ListMultiMap[K, V]{m: m, len: mm.len - 1}
+}
+
+// RemoveAll returns a new ListMultiMap without k and any of its values.
+func (mm ListMultiMap[K, V]) RemoveAll(k K) ListMultiMap[K, V] {
+	vs, ok := mm.m.Get(k)
+	if !ok {
+		return mm
+	}
+	return ListMultiMap[K, V]{m: mm.m.Delete(k), len: mm.len - len(vs)}
+}
+
+// Get returns an iterator over the values of k in the order they were added,
+// which yields nothing if k is absent.
+func (mm ListMultiMap[K, V]) Get(k K) iter.Seq[V] {
+	vs, _ := mm.m.Get(k)
+	return slices.Values(vs)
+}
+
+// Has returns true if k has at least one value.
+func (mm ListMultiMap[K, V]) Has(k K) bool {
+	return mm.m.Has(k)
+}
+
+// Count returns the number of values of k, counting duplicates.
+func (mm ListMultiMap[K, V]) Count(k K) int {
+	vs, _ := mm.m.Get(k)
+	return len(vs)
+}
+
+// Len returns the number of key-value pairs in the ListMultiMap.
+func (mm ListMultiMap[K, V]) Len() int {
+	return mm.len
+}
+
+// KeyLen returns the number of distinct keys in the ListMultiMap.
+func (mm ListMultiMap[K, V]) KeyLen() int {
+	return mm.m.Len()
+}
+
+// KeysSeq returns an iterator over the distinct keys of the ListMultiMap.
+func (mm ListMultiMap[K, V]) KeysSeq() iter.Seq[K] {
+	return mm.m.KeysSeq()
+}
+
+// All returns an iterator over every key-value pair, yielding a key once for
+// each of its values, in order. It implements [Iterable2].
+func (mm ListMultiMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		mm.m.ForEach(func(k K, vs Vec[V]) bool {
+			for _, v := range vs {
+				if !yield(k, v) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+}
+
+// Equal returns true if both ListMultiMaps have the same keys with the same
+// values in the same order.
+func (mm ListMultiMap[K, V]) Equal(other ListMultiMap[K, V]) bool {
+	return mm.len == other.len && mm.m.EqualFunc(other.m, func(a, b Vec[V]) bool {
+		return slices.EqualFunc(a, b, valuesEqual[V])
+	})
+}
+
+var (
+	_ Iterable2[int, int] = MultiMap[int, int]{}
+	_ Iterable2[int, int] = ListMultiMap[int, int]{}
+)
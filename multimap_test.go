@@ -0,0 +1,124 @@
+package fn
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestMultiMap(t *testing.T) {
+	var mm MultiMap[string, int]
+	mm = mm.Add("a", 1).Add("a", 2).Add("b", 3)
+	if same := mm.Add("a", 1); same.Len() != 3 {
+		t.Errorf("expected adding an existing pair to change nothing, got len %d", same.Len())
+	}
+	if mm.Len() != 3 || mm.KeyLen() != 2 {
+		t.Errorf("expected 3 pairs under 2 keys, got %d and %d", mm.Len(), mm.KeyLen())
+	}
+	if got := slices.Sorted(mm.Get("a")); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("expected a -> [1 2], got %v", got)
+	}
+	if mm.Count("a") != 2 || mm.Count("missing") != 0 || !mm.Contains("b", 3) || mm.Contains("b", 1) {
+		t.Error("unexpected counts or membership")
+	}
+	if n := Len(mm.Get("missing")); n != 0 {
+		t.Errorf("expected no values for a missing key, got %d", n)
+	}
+	if !mm.Values("a").Equal(SetOf(1, 2)) || mm.Values("missing").Len() != 0 {
+		t.Error("unexpected value sets")
+	}
+
+	removed := mm.Remove("a", 1).Remove("a", 9).Remove("missing", 1)
+	if removed.Len() != 2 || removed.Contains("a", 1) || !mm.Contains("a", 1) {
+		t.Error("expected Remove to drop one pair and leave the original unchanged")
+	}
+	if gone := removed.Remove("b", 3); gone.Has("b") || gone.KeyLen() != 1 {
+		t.Error("expected removing the last value to remove the key")
+	}
+	if all := mm.RemoveAll("a"); all.Len() != 1 || all.Has("a") || !all.Has("b") {
+		t.Errorf("expected RemoveAll to drop every value of a, got len %d", all.Len())
+	}
+
+	pairs := make(map[string]int)
+	for k, v := range mm.All() {
+		pairs[fmt.Sprint(k, v)]++
+	}
+	if want := map[string]int{"a1": 1, "a2": 1, "b3": 1}; !maps.Equal(pairs, want) {
+		t.Errorf("expected %v, got %v", want, pairs)
+	}
+	if keys := slices.Sorted(mm.KeysSeq()); !slices.Equal(keys, []string{"a", "b"}) {
+		t.Errorf("expected keys [a b], got %v", keys)
+	}
+	if !mm.Equal(NewMultiMap[string, int]().Add("b", 3).Add("a", 2).Add("a", 1)) || mm.Equal(removed) {
+		t.Error("unexpected Equal result")
+	}
+}
+
+func TestMultiMapHashers(t *testing.T) {
+	mm := NewMultiMapWith[string, string](FoldHasher{}, FoldHasher{}).Add("Tag", "X").Add("TAG", "x")
+	if mm.Len() != 1 || !mm.Contains("tag", "X") {
+		t.Errorf("expected keys and values to fold case, got len %d", mm.Len())
+	}
+	if mm.Add("tag", "y").Count("TAG") != 2 {
+		t.Error("expected new values to share the key")
+	}
+
+	// Absent keys never reach the default hasher with uncomparable values
+	bytes := NewMultiMapWith[string, []byte](nil, BytesHasher{}).Add("a", []byte("x"))
+	if bytes.Contains("missing", []byte("x")) || bytes.Count("missing") != 0 {
+		t.Error("expected an absent key to have no values")
+	}
+	if !bytes.Contains("a", []byte("x")) || !bytes.Values("missing").Add([]byte("y")).Contains([]byte("y")) {
+		t.Error("expected byte slice values to be found by content")
+	}
+}
+
+func TestListMultiMap(t *testing.T) {
+	var mm ListMultiMap[string, []int]
+	mm = mm.Add("a", []int{1}).Add("a", []int{2}).Add("a", []int{1}).Add("b", nil)
+	if mm.Len() != 4 || mm.KeyLen() != 2 || mm.Count("a") != 3 {
+		t.Errorf("expected 4 pairs under 2 keys, got %d and %d", mm.Len(), mm.KeyLen())
+	}
+	if got := fmt.Sprint(slices.Collect(mm.Get("a"))); got != "[[1] [2] [1]]" {
+		t.Errorf("expected values in insertion order, got %s", got)
+	}
+
+	removed := mm.Remove("a", []int{1})
+	if got := fmt.Sprint(slices.Collect(removed.Get("a"))); got != "[[2] [1]]" {
+		t.Errorf("expected only the first occurrence removed, got %s", got)
+	}
+	if mm.Count("a") != 3 || removed.Len() != 3 {
+		t.Error("expected the original to be unchanged")
+	}
+	if same := mm.Remove("a", []int{9}); same.Len() != 4 {
+		t.Error("expected removing a missing value to change nothing")
+	}
+	if gone := mm.Remove("b", nil); gone.Has("b") {
+		t.Error("expected removing the last value to remove the key")
+	}
+	if all := mm.RemoveAll("a"); all.Len() != 1 || all.Has("a") {
+		t.Errorf("expected RemoveAll to drop every value of a, got len %d", all.Len())
+	}
+
+	// Appending to a shared list must not leak into other versions
+	base := NewListMultiMap[string, int]().Add("k", 1).Add("k", 2).Add("k", 3)
+	x, y := base.Add("k", 4), base.Add("k", 5)
+	if got := slices.Collect(x.Get("k")); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("expected [1 2 3 4], got %v", got)
+	}
+	if got := slices.Collect(y.Get("k")); !slices.Equal(got, []int{1, 2, 3, 5}) {
+		t.Errorf("expected [1 2 3 5], got %v", got)
+	}
+
+	n := 0
+	for k, v := range base.All() {
+		if k != "k" || v != n+1 {
+			t.Errorf("unexpected pair %s=%d", k, v)
+		}
+		n++
+	}
+	if !base.Equal(NewListMultiMap[string, int]().Add("k", 1).Add("k", 2).Add("k", 3)) || base.Equal(x) {
+		t.Error("unexpected Equal result")
+	}
+}
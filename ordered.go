@@ -0,0 +1,587 @@
+package fn
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"reflect"
+	"slices"
+)
+
+const (
+	degree   = 16           // minimum branching factor of the B-tree
+	maxItems = 2*degree - 1 // entries in a full node
+	minItems = degree - 1   // entries in a minimal non-root node
+)
+
+// bnode is a B-tree node. Its entries are sorted by key; an interior node has
+// one more child than entries, with children[i] holding the keys that sort
+// between items[i-1] and items[i]. Leaves have nil children.
+type bnode[K any, V Val] struct {
+	items    []leaf[K, V]
+	children []*bnode[K, V]
+	edit     *owner // builder allowed to mutate this node in place, if any
+}
+
+// compareOrdered orders keys of any type whose underlying type is an integer,
+// float, or string, like [cmp.Compare]. It panics for other key types.
+func compareOrdered[K any](a, b K) int {
+	// Common key types are compared directly, without reflection.
+	switch x := any(a).(type) {
+	case string:
+		return cmp.Compare(x, any(b).(string))
+	case int:
+		return cmp.Compare(x, any(b).(int))
+	case int64:
+		return cmp.Compare(x, any(b).(int64))
+	case uint64:
+		return cmp.Compare(x, any(b).(uint64))
+	case float64:
+		return cmp.Compare(x, any(b).(float64))
+	}
+	va, vb := reflect.ValueOf(&a).Elem(), reflect.ValueOf(&b).Elem()
+	switch va.Kind() {
+	case reflect.String:
+		return cmp.Compare(va.String(), vb.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(va.Int(), vb.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return cmp.Compare(va.Uint(), vb.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(va.Float(), vb.Float())
+	}
+	panic(fmt.Sprintf("fn: cannot order keys of type %v; use NewOrderedMapFunc", va.Type()))
+}
+
+// search returns the position of the first entry in n not less than k, and
+// whether that entry's key equals k.
+func (n *bnode[K, V]) search(compare func(a, b K) int, k K) (int, bool) {
+	return slices.BinarySearchFunc(n.items, k, func(l leaf[K, V], k K) int {
+		return compare(l.key, k)
+	})
+}
+
+// get retrieves a value from the tree by key
+func (n *bnode[K, V]) get(compare func(a, b K) int, k K) (V, bool) {
+	for n != nil {
+		i, found := n.search(compare, k)
+		if found {
+			return n.items[i].val, true
+		}
+		if n.children == nil {
+			break
+		}
+		n = n.children[i]
+	}
+	var zero V
+	return zero, false
+}
+
+// floor returns the entry with the greatest key not greater than k.
+func (n *bnode[K, V]) floor(compare func(a, b K) int, k K) (leaf[K, V], bool) {
+	var best leaf[K, V]
+	ok := false
+	for n != nil {
+		i, found := n.search(compare, k)
+		if found {
+			return n.items[i], true
+		}
+		if i > 0 {
+			best, ok = n.items[i-1], true
+		}
+		if n.children == nil {
+			break
+		}
+		n = n.children[i]
+	}
+	return best, ok
+}
+
+// ceiling returns the entry with the least key not less than k.
+func (n *bnode[K, V]) ceiling(compare func(a, b K) int, k K) (leaf[K, V], bool) {
+	var best leaf[K, V]
+	ok := false
+	for n != nil {
+		i, found := n.search(compare, k)
+		if found {
+			return n.items[i], true
+		}
+		if i < len(n.items) {
+			best, ok = n.items[i], true
+		}
+		if n.children == nil {
+			break
+		}
+		n = n.children[i]
+	}
+	return best, ok
+}
+
+// min returns the entry with the least key.
+func (n *bnode[K, V]) min() (leaf[K, V], bool) {
+	if n == nil {
+		return leaf[K, V]{}, false
+	}
+	for n.children != nil {
+		n = n.children[0]
+	}
+	return n.items[0], true
+}
+
+// max returns the entry with the greatest key.
+func (n *bnode[K, V]) max() (leaf[K, V], bool) {
+	if n == nil {
+		return leaf[K, V]{}, false
+	}
+	for n.children != nil {
+		n = n.children[len(n.children)-1]
+	}
+	return n.items[len(n.items)-1], true
+}
+
+// ascend calls fn for each entry with a key not less than lo, or for every
+// entry if lo is nil, in ascending key order. It stops early and returns
+// false if fn returns false.
+func (n *bnode[K, V]) ascend(compare func(a, b K) int, lo *K, fn func(K, V) bool) bool {
+	if n == nil {
+		return true
+	}
+	i := 0
+	if lo != nil {
+		var found bool
+		i, found = n.search(compare, *lo)
+		if !found && n.children != nil && !n.children[i].ascend(compare, lo, fn) {
+			return false
+		}
+		// children[i] is now either visited or entirely below lo
+		if i < len(n.items) && !fn(n.items[i].key, n.items[i].val) {
+			return false
+		}
+		i++
+	}
+	// The remaining subtrees lie entirely above lo
+	for ; i <= len(n.items); i++ {
+		if n.children != nil && !n.children[i].ascend(compare, nil, fn) {
+			return false
+		}
+		if i < len(n.items) && !fn(n.items[i].key, n.items[i].val) {
+			return false
+		}
+	}
+	return true
+}
+
+// descend calls fn for every entry in descending key order. It stops early
+// and returns false if fn returns false.
+func (n *bnode[K, V]) descend(fn func(K, V) bool) bool {
+	if n == nil {
+		return true
+	}
+	for i := len(n.items); i >= 0; i-- {
+		if i < len(n.items) && !fn(n.items[i].key, n.items[i].val) {
+			return false
+		}
+		if n.children != nil && !n.children[i].descend(fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// owned returns n if it is owned by e, or a copy of n owned by e whose
+// arrays may be mutated freely.
+func (n *bnode[K, V]) owned(e *owner) *bnode[K, V] {
+	if n.edit == e {
+		return n
+	}
+	return &bnode[K, V]{
+		items:    slices.Clone(n.items),
+		children: slices.Clone(n.children),
+		edit:     e,
+	}
+}
+
+// child returns the i'th child of n ready for mutation by e, replacing it
+// with an owned copy first if needed. n must already be owned by e.
+func (n *bnode[K, V]) child(e *owner, i int) *bnode[K, V] {
+	c := n.children[i].owned(e)
+	n.children[i] = c
+	return c
+}
+
+// split moves the upper half of n, a full node owned by e, into a new node
+// and returns the median entry along with it.
+func (n *bnode[K, V]) split(e *owner) (leaf[K, V], *bnode[K, V]) {
+	m := len(n.items) / 2
+	mid := n.items[m]
+	right := &bnode[K, V]{items: slices.Clone(n.items[m+1:]), edit: e}
+	clear(n.items[m:])
+	n.items = n.items[:m]
+	if n.children != nil {
+		right.children = slices.Clone(n.children[m+1:])
+		clear(n.children[m+1:])
+		n.children = n.children[:m+1]
+	}
+	return mid, right
+}
+
+// insertMut adds or updates k in n, splitting full nodes on the way down so
+// that a split never has to propagate back up. n must be owned by e and not
+// be full. It reports whether k was newly added.
+func (n *bnode[K, V]) insertMut(compare func(a, b K) int, e *owner, k K, v V) bool {
+	for {
+		i, found := n.search(compare, k)
+		if found {
+			n.items[i].val = v
+			return false
+		}
+		if n.children == nil {
+			n.items = slices.Insert(n.items, i, leaf[K, V]{key: k, val: v})
+			return true
+		}
+
+		c := n.child(e, i)
+		if len(c.items) == maxItems {
+			mid, right := c.split(e)
+			n.items = slices.Insert(n.items, i, mid)
+			n.children = slices.Insert(n.children, i+1, right)
+			if r := compare(k, mid.key); r == 0 {
+				n.items[i].val = v
+				return false
+			} else if r > 0 {
+				c = right
+			}
+		}
+		n = c
+	}
+}
+
+// deleteMut removes k, which must be present, from n. n must be owned by e.
+// Children left with too few entries are refilled from a sibling or merged
+// with one on the way back up.
+func (n *bnode[K, V]) deleteMut(compare func(a, b K) int, e *owner, k K) {
+	i, found := n.search(compare, k)
+	if n.children == nil {
+		n.items = slices.Delete(n.items, i, i+1)
+		return
+	}
+	if found {
+		// Replace k with its predecessor, the greatest entry to its left
+		n.items[i] = n.child(e, i).deleteMaxMut(e)
+	} else {
+		n.child(e, i).deleteMut(compare, e, k)
+	}
+	n.rebalance(e, i)
+}
+
+// deleteMaxMut removes and returns the greatest entry in n, which must be
+// owned by e.
+func (n *bnode[K, V]) deleteMaxMut(e *owner) leaf[K, V] {
+	if n.children == nil {
+		last := n.items[len(n.items)-1]
+		n.items = slices.Delete(n.items, len(n.items)-1, len(n.items))
+		return last
+	}
+	i := len(n.children) - 1
+	last := n.child(e, i).deleteMaxMut(e)
+	n.rebalance(e, i)
+	return last
+}
+
+// rebalance restores the minimum size of children[i], which must be owned
+// by e, after a delete below it, by borrowing an entry through n from a
+// sibling that can spare one or else merging with a sibling.
+func (n *bnode[K, V]) rebalance(e *owner, i int) {
+	c := n.children[i]
+	if len(c.items) >= minItems {
+		return
+	}
+
+	switch {
+	case i > 0 && len(n.children[i-1].items) > minItems:
+		left := n.child(e, i-1)
+		last := len(left.items) - 1
+		c.items = slices.Insert(c.items, 0, n.items[i-1])
+		n.items[i-1] = left.items[last]
+		left.items = slices.Delete(left.items, last, last+1)
+		if c.children != nil {
+			c.children = slices.Insert(c.children, 0, left.children[last+1])
+			left.children = slices.Delete(left.children, last+1, last+2)
+		}
+
+	case i < len(n.items) && len(n.children[i+1].items) > minItems:
+		right := n.child(e, i+1)
+		c.items = append(c.items, n.items[i])
+		n.items[i] = right.items[0]
+		right.items = slices.Delete(right.items, 0, 1)
+		if c.children != nil {
+			c.children = append(c.children, right.children[0])
+			right.children = slices.Delete(right.children, 0, 1)
+		}
+
+	default:
+		if i == len(n.items) {
+			i--
+		}
+		left, right := n.child(e, i), n.children[i+1]
+		left.items = append(append(left.items, n.items[i]), right.items...)
+		left.children = append(left.children, right.children...)
+		n.items = slices.Delete(n.items, i, i+1)
+		n.children = slices.Delete(n.children, i+1, i+2)
+	}
+}
+
+// OrderedMap is an immutable map that keeps its keys sorted, using a
+// persistent B-tree. Like [Map], all operations return a new OrderedMap,
+// leaving the original unchanged and sharing every node the operation did
+// not touch. Iteration is in ascending key order.
+//
+// The zero value is an empty map ordering keys with [cmp.Compare], which
+// requires a key type whose underlying type is an integer, float, or string.
+// Use [NewOrderedMapFunc] to order other keys.
+type OrderedMap[K any, V Val] struct {
+	root    *bnode[K, V]
+	len     int
+	compare func(a, b K) int // nil means compareOrdered
+}
+
+// NewOrderedMap creates an empty OrderedMap ordered by [cmp.Compare].
+func NewOrderedMap[K cmp.Ordered, V Val]() OrderedMap[K, V] {
+	return OrderedMap[K, V]{compare: cmp.Compare[K]}
+}
+
+// NewOrderedMapFunc creates an empty OrderedMap ordered by compare, which
+// returns a negative number when a < b, a positive number when a > b, and
+// zero when the keys are equal. Maps derived from it keep compare.
+func NewOrderedMapFunc[K any, V Val](compare func(a, b K) int) OrderedMap[K, V] {
+	return OrderedMap[K, V]{compare: compare}
+}
+
+// cmp returns the OrderedMap's comparison function.
+func (m OrderedMap[K, V]) cmp() func(a, b K) int {
+	return compareOr(m.compare)
+}
+
+// compareOr returns compare, or compareOrdered if compare is nil.
+func compareOr[K any](compare func(a, b K) int) func(a, b K) int {
+	if compare == nil {
+		return compareOrdered[K]
+	}
+	return compare
+}
+
+// Get retrieves a value by key. Returns the value and true if found,
+// or the zero value and false if not found.
+func (m OrderedMap[K, V]) Get(k K) (V, bool) {
+	return m.root.get(m.cmp(), k)
+}
+
+// Has returns true if the key exists in the OrderedMap.
+func (m OrderedMap[K, V]) Has(k K) bool {
+	_, ok := m.Get(k)
+	return ok
+}
+
+// Set returns a new OrderedMap with the key-value pair added or updated.
+// The original OrderedMap is unchanged.
+func (m OrderedMap[K, V]) Set(k K, v V) OrderedMap[K, V] {
+	return m.Batch(func(b *OrderedBuilder[K, V]) { b.Set(k, v) })
+}
+
+// Delete returns a new OrderedMap with the key removed.
+// The original OrderedMap is unchanged. Returns the same OrderedMap if key not found.
+func (m OrderedMap[K, V]) Delete(k K) OrderedMap[K, V] {
+	if !m.Has(k) {
+		return m
+	}
+	return m.Batch(func(b *OrderedBuilder[K, V]) { b.Delete(k) })
+}
+
+// Len returns the number of key-value pairs in the OrderedMap.
+func (m OrderedMap[K, V]) Len() int {
+	return m.len
+}
+
+// Floor returns the entry with the greatest key less than or equal to k, and
+// false if there is none.
+func (m OrderedMap[K, V]) Floor(k K) (K, V, bool) {
+	l, ok := m.root.floor(m.cmp(), k)
+	return l.key, l.val, ok
+}
+
+// Ceiling returns the entry with the least key greater than or equal to k,
+// and false if there is none.
+func (m OrderedMap[K, V]) Ceiling(k K) (K, V, bool) {
+	l, ok := m.root.ceiling(m.cmp(), k)
+	return l.key, l.val, ok
+}
+
+// Min returns the entry with the least key, and false if the OrderedMap is empty.
+func (m OrderedMap[K, V]) Min() (K, V, bool) {
+	l, ok := m.root.min()
+	return l.key, l.val, ok
+}
+
+// Max returns the entry with the greatest key, and false if the OrderedMap is empty.
+func (m OrderedMap[K, V]) Max() (K, V, bool) {
+	l, ok := m.root.max()
+	return l.key, l.val, ok
+}
+
+// All returns an iterator over every key-value pair in ascending key order.
+// It implements [Iterable2].
+func (m OrderedMap[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.root.ascend(m.cmp(), nil, yield)
+	}
+}
+
+// Backward returns an iterator over every key-value pair in descending key order.
+func (m OrderedMap[K, V]) Backward() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.root.descend(yield)
+	}
+}
+
+// Range returns an iterator over the key-value pairs with lo <= key < hi, in
+// ascending key order. Only the part of the tree within the bounds is visited.
+func (m OrderedMap[K, V]) Range(lo, hi K) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		compare := m.cmp()
+		m.root.ascend(compare, &lo, func(k K, v V) bool {
+			return compare(k, hi) < 0 && yield(k, v)
+		})
+	}
+}
+
+var _ Iterable2[int, int] = OrderedMap[int, int]{}
+
+// Keys returns a slice of all keys in ascending order.
+func (m OrderedMap[K, V]) Keys() []K {
+	keys := make([]K, 0, m.len)
+	for k := range m.All() {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// Values returns a slice of all values in ascending key order.
+func (m OrderedMap[K, V]) Values() []V {
+	vals := make([]V, 0, m.len)
+	for _, v := range m.All() {
+		vals = append(vals, v)
+	}
+	return vals
+}
+
+// ToBuilder returns an OrderedBuilder seeded with the OrderedMap's entries.
+// Edits through the builder copy only the nodes they touch, once each, and
+// never affect m.
+func (m OrderedMap[K, V]) ToBuilder() *OrderedBuilder[K, V] {
+	return &OrderedBuilder[K, V]{root: m.root, len: m.len, compare: m.compare}
+}
+
+// Batch applies a group of edits to m through an [OrderedBuilder] and returns
+// the resulting OrderedMap, copying each touched node at most once.
+func (m OrderedMap[K, V]) Batch(f func(*OrderedBuilder[K, V])) OrderedMap[K, V] {
+	b := m.ToBuilder()
+	f(b)
+	return b.Build()
+}
+
+// OrderedBuilder provides efficient mutable construction of an immutable
+// OrderedMap, in the same way [Builder] does for [Map]. It copies each node
+// the first time it modifies it and then mutates its private copy.
+// After calling Build(), the OrderedBuilder must not be reused; doing so panics.
+type OrderedBuilder[K any, V Val] struct {
+	root    *bnode[K, V]
+	len     int
+	compare func(a, b K) int
+	edit    *owner
+	built   bool
+}
+
+// NewOrderedBuilder creates a new OrderedBuilder ordered by [cmp.Compare].
+func NewOrderedBuilder[K cmp.Ordered, V Val]() *OrderedBuilder[K, V] {
+	return &OrderedBuilder[K, V]{compare: cmp.Compare[K]}
+}
+
+// NewOrderedBuilderFunc creates a new OrderedBuilder ordered by compare.
+func NewOrderedBuilderFunc[K any, V Val](compare func(a, b K) int) *OrderedBuilder[K, V] {
+	return &OrderedBuilder[K, V]{compare: compare}
+}
+
+// own readies the builder for a mutation and returns its ownership token.
+func (b *OrderedBuilder[K, V]) own() *owner {
+	if b.built {
+		panic("fn: OrderedBuilder used after Build")
+	}
+	if b.edit == nil {
+		b.edit = &owner{}
+	}
+	if b.root != nil {
+		b.root = b.root.owned(b.edit)
+	}
+	return b.edit
+}
+
+// Set adds or updates a key-value pair. Mutates the builder in place.
+func (b *OrderedBuilder[K, V]) Set(k K, v V) *OrderedBuilder[K, V] {
+	e := b.own()
+	if b.root == nil {
+		b.root = &bnode[K, V]{items: []leaf[K, V]{{key: k, val: v}}, edit: e}
+		b.len++
+		return b
+	}
+	if len(b.root.items) == maxItems {
+		left := b.root
+		mid, right := left.split(e)
+		b.root = &bnode[K, V]{
+			items:    []leaf[K, V]{mid},
+			children: []*bnode[K, V]{left, right},
+			edit:     e,
+		}
+	}
+	if b.root.insertMut(compareOr(b.compare), e, k, v) {
+		b.len++
+	}
+	return b
+}
+
+// Delete removes a key. Mutates the builder in place.
+func (b *OrderedBuilder[K, V]) Delete(k K) *OrderedBuilder[K, V] {
+	if b.built {
+		panic("fn: OrderedBuilder used after Build")
+	}
+	compare := compareOr(b.compare)
+	// Look first so that deleting a missing key copies nothing
+	if _, ok := b.root.get(compare, k); !ok {
+		return b
+	}
+	e := b.own()
+	b.root.deleteMut(compare, e, k)
+	b.len--
+	switch {
+	case len(b.root.items) > 0:
+	case b.root.children != nil:
+		b.root = b.root.children[0]
+	default:
+		b.root = nil
+	}
+	return b
+}
+
+// Len returns the current number of entries.
+func (b *OrderedBuilder[K, V]) Len() int {
+	return b.len
+}
+
+// Build returns the constructed OrderedMap.
+// The OrderedBuilder must not be used after calling Build.
+func (b *OrderedBuilder[K, V]) Build() OrderedMap[K, V] {
+	if b.built {
+		panic("fn: OrderedBuilder used after Build")
+	}
+	b.built = true
+	return OrderedMap[K, V]{root: b.root, len: b.len, compare: b.compare}
+}
@@ -0,0 +1,293 @@
+package fn
+
+import (
+	"cmp"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+// checkTree verifies the B-tree invariants of n: sorted entries, node sizes
+// within bounds, and all leaves at the same depth. It returns the number of
+// entries and the height.
+func checkTree[K any, V Val](t *testing.T, n *bnode[K, V], compare func(a, b K) int, root bool) (int, int) {
+	t.Helper()
+	if n == nil {
+		return 0, 0
+	}
+	if len(n.items) > maxItems || (!root && len(n.items) < minItems) || len(n.items) == 0 {
+		t.Fatalf("node has %d entries", len(n.items))
+	}
+	if !slices.IsSortedFunc(n.items, func(a, b leaf[K, V]) int { return compare(a.key, b.key) }) {
+		t.Fatal("node entries are not sorted")
+	}
+	if n.children == nil {
+		return len(n.items), 1
+	}
+	if len(n.children) != len(n.items)+1 {
+		t.Fatalf("node has %d entries but %d children", len(n.items), len(n.children))
+	}
+	count, height := len(n.items), -1
+	for i, c := range n.children {
+		if i > 0 && compare(c.items[0].key, n.items[i-1].key) <= 0 {
+			t.Fatalf("child %d sorts before its separator", i)
+		}
+		if i < len(n.items) && compare(c.items[len(c.items)-1].key, n.items[i].key) >= 0 {
+			t.Fatalf("child %d sorts after its separator", i)
+		}
+		cn, ch := checkTree(t, c, compare, false)
+		if height >= 0 && ch != height {
+			t.Fatalf("leaves at different depths: %d and %d", height, ch)
+		}
+		count, height = count+cn, ch
+	}
+	return count, height + 1
+}
+
+// checkOrdered verifies that m holds exactly the entries of want, in order.
+func checkOrdered(t *testing.T, m OrderedMap[int, int], want map[int]int) {
+	t.Helper()
+	if n, _ := checkTree(t, m.root, m.cmp(), true); n != m.Len() {
+		t.Fatalf("Len %d disagrees with tree count %d", m.Len(), n)
+	}
+	if m.Len() != len(want) {
+		t.Fatalf("expected len %d, got %d", len(want), m.Len())
+	}
+	keys := make([]int, 0, len(want))
+	for k := range want {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	if got := m.Keys(); !slices.Equal(got, keys) {
+		t.Fatalf("expected keys %v, got %v", keys, got)
+	}
+	for k, v := range want {
+		if got, ok := m.Get(k); !ok || got != v {
+			t.Fatalf("key %d: expected %d, got %d, %v", k, v, got, ok)
+		}
+	}
+}
+
+func TestOrderedMapRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	m := NewOrderedMap[int, int]()
+	want := make(map[int]int)
+	var versions []OrderedMap[int, int]
+	var snapshots []map[int]int
+
+	for i := range 5000 {
+		k := r.IntN(1000)
+		if r.IntN(3) == 0 {
+			m = m.Delete(k)
+			delete(want, k)
+		} else {
+			m = m.Set(k, i)
+			want[k] = i
+		}
+		if i%500 == 0 {
+			versions = append(versions, m)
+			snapshots = append(snapshots, maps.Clone(want))
+		}
+	}
+	checkOrdered(t, m, want)
+
+	// Earlier versions are unaffected by later edits
+	for i, v := range versions {
+		checkOrdered(t, v, snapshots[i])
+	}
+
+	// Delete everything
+	for k := range want {
+		m = m.Delete(k)
+	}
+	if m.Len() != 0 || m.root != nil {
+		t.Errorf("expected an empty tree, got len %d", m.Len())
+	}
+}
+
+func TestOrderedMapQueries(t *testing.T) {
+	var m OrderedMap[int, string] // the zero value orders with cmp.Compare
+	if _, _, ok := m.Min(); ok {
+		t.Error("expected Min of an empty map to fail")
+	}
+	if _, _, ok := m.Floor(10); ok {
+		t.Error("expected Floor of an empty map to fail")
+	}
+
+	for i := 0; i < 1000; i += 10 {
+		m = m.Set(i, "v")
+	}
+
+	tests := []struct {
+		k                int
+		floor, ceiling   int
+		hasFloor, hasCei bool
+	}{
+		{k: -5, ceiling: 0, hasCei: true},
+		{k: 0, floor: 0, ceiling: 0, hasFloor: true, hasCei: true},
+		{k: 15, floor: 10, ceiling: 20, hasFloor: true, hasCei: true},
+		{k: 500, floor: 500, ceiling: 500, hasFloor: true, hasCei: true},
+		{k: 991, floor: 990, hasFloor: true},
+	}
+	for _, tt := range tests {
+		if k, _, ok := m.Floor(tt.k); ok != tt.hasFloor || k != tt.floor {
+			t.Errorf("Floor(%d): expected %d, %v, got %d, %v", tt.k, tt.floor, tt.hasFloor, k, ok)
+		}
+		if k, _, ok := m.Ceiling(tt.k); ok != tt.hasCei || k != tt.ceiling {
+			t.Errorf("Ceiling(%d): expected %d, %v, got %d, %v", tt.k, tt.ceiling, tt.hasCei, k, ok)
+		}
+	}
+
+	if k, _, _ := m.Min(); k != 0 {
+		t.Errorf("expected Min 0, got %d", k)
+	}
+	if k, _, _ := m.Max(); k != 990 {
+		t.Errorf("expected Max 990, got %d", k)
+	}
+
+	var got []int
+	for k := range m.Range(95, 150) {
+		got = append(got, k)
+	}
+	if want := []int{100, 110, 120, 130, 140}; !slices.Equal(got, want) {
+		t.Errorf("Range(95, 150): expected %v, got %v", want, got)
+	}
+
+	got = got[:0]
+	for k := range m.Range(100, 100) {
+		got = append(got, k)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected an empty range, got %v", got)
+	}
+
+	got = got[:0]
+	for k := range m.Range(-100, 2000) {
+		got = append(got, k)
+		if len(got) == 3 {
+			break
+		}
+	}
+	if want := []int{0, 10, 20}; !slices.Equal(got, want) {
+		t.Errorf("expected early stop at %v, got %v", want, got)
+	}
+
+	backward := m.Keys()
+	slices.Reverse(backward)
+	got = got[:0]
+	for k := range m.Backward() {
+		got = append(got, k)
+	}
+	if !slices.Equal(got, backward) {
+		t.Errorf("expected Backward to reverse All")
+	}
+}
+
+func TestOrderedMapFunc(t *testing.T) {
+	type point struct{ x, y int }
+	byY := func(a, b point) int { return cmp.Or(cmp.Compare(a.y, b.y), cmp.Compare(a.x, b.x)) }
+
+	m := NewOrderedMapFunc[point, string](byY)
+	m = m.Set(point{1, 3}, "c").Set(point{5, 1}, "a").Set(point{0, 2}, "b")
+	if got := m.Values(); !slices.Equal(got, []string{"a", "b", "c"}) {
+		t.Errorf("expected values ordered by y, got %v", got)
+	}
+	if !m.Delete(point{0, 2}).Set(point{9, 9}, "z").Has(point{9, 9}) {
+		t.Error("expected derived maps to keep the comparator")
+	}
+
+	type name string
+	var named OrderedMap[name, int]
+	named = named.Set("b", 2).Set("a", 1)
+	if got := named.Keys(); !slices.Equal(got, []name{"a", "b"}) {
+		t.Errorf("expected named string keys in order, got %v", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected the zero value to panic on unordered keys")
+		}
+	}()
+	var bad OrderedMap[point, int]
+	bad.Set(point{}, 1).Set(point{1, 1}, 2)
+}
+
+func TestOrderedMapSharing(t *testing.T) {
+	b := NewOrderedBuilder[int, int]()
+	for i := range 10000 {
+		b.Set(i, i)
+	}
+	m := b.Build()
+
+	// A Set copies only the path to its key
+	m2 := m.Set(0, -1)
+	shared := 0
+	for i, c := range m.root.children {
+		if m2.root.children[i] == c {
+			shared++
+		}
+	}
+	if shared != len(m.root.children)-1 {
+		t.Errorf("expected all but one root child to be shared, got %d of %d", shared, len(m.root.children))
+	}
+	if v, _ := m.Get(0); v != 0 {
+		t.Errorf("expected the original map to be unchanged, got %d", v)
+	}
+	if m.Delete(-1).root != m.root {
+		t.Error("expected deleting a missing key to return the same tree")
+	}
+}
+
+func TestOrderedBuilder(t *testing.T) {
+	m := NewOrderedMap[string, int]().Set("a", 1).Set("b", 2)
+
+	got := m.Batch(func(b *OrderedBuilder[string, int]) {
+		b.Set("c", 3).Delete("a").Set("b", 20)
+		if b.Len() != 2 {
+			t.Errorf("expected builder len 2, got %d", b.Len())
+		}
+	})
+	if keys := got.Keys(); !slices.Equal(keys, []string{"b", "c"}) {
+		t.Errorf("expected keys [b c], got %v", keys)
+	}
+	if v, _ := got.Get("b"); v != 20 {
+		t.Errorf("expected b=20, got %d", v)
+	}
+	if keys := m.Keys(); !slices.Equal(keys, []string{"a", "b"}) {
+		t.Errorf("expected original keys [a b], got %v", keys)
+	}
+
+	b := m.ToBuilder()
+	b.Build()
+	defer func() {
+		if recover() == nil {
+			t.Error("expected use after Build to panic")
+		}
+	}()
+	b.Set("x", 1)
+}
+
+func BenchmarkOrderedMapSet(b *testing.B) {
+	m := NewOrderedMap[int, int]()
+	for i := range 100000 {
+		m = m.Set(i, i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		m.Set(i%100000, i)
+	}
+}
+
+func BenchmarkOrderedMapGet(b *testing.B) {
+	bld := NewOrderedBuilder[int, int]()
+	for i := range 100000 {
+		bld.Set(i, i)
+	}
+	m := bld.Build()
+	b.ResetTimer()
+	for i := range b.N {
+		m.Get(i % 100000)
+	}
+}
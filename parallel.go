@@ -0,0 +1,145 @@
+package fn
+
+import (
+	"iter"
+	"runtime"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelThreshold is the number of entries below which SetAll inserts
+// serially, since starting workers would cost more than it saves.
+const parallelThreshold = 1 << 12
+
+// hashedLeaf is a leaf along with its key's hash.
+type hashedLeaf[K any, V Val] struct {
+	leaf[K, V]
+	h hashedKey
+}
+
+// MapFromParallel creates a Map from the pairs of seq using up to workers
+// goroutines, or GOMAXPROCS goroutines if workers is not positive. If seq
+// yields a key more than once, its last value wins. See [Builder.SetAll].
+func MapFromParallel[K comparable, V Val](seq iter.Seq2[K, V], workers int) Map[K, V] {
+	return NewBuilder[K, V]().SetAll(seq, workers).Build()
+}
+
+// SetAll adds or updates every pair of seq, as if by calling Set for each in
+// order, using up to workers goroutines, or GOMAXPROCS goroutines if workers
+// is not positive. Mutates the builder in place.
+//
+// seq is read once, serially. The entries are then hashed in parallel and
+// partitioned by the hash bits that select their slot in the root node, and
+// each root subtree is built by a single worker, so workers never touch the
+// same node and need no locking. Small inputs are inserted serially.
+func (b *Builder[K, V]) SetAll(seq iter.Seq2[K, V], workers int) *Builder[K, V] {
+	e := b.own()
+	hs := hasherOr(b.hasher)
+
+	var entries []leaf[K, V]
+	for k, v := range seq {
+		entries = append(entries, leaf[K, V]{key: k, val: v})
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers == 1 || len(entries) < parallelThreshold {
+		for _, l := range entries {
+			if b.root.insertMut(hs, e, l.key, l.val, hashOf(hs, l.key), 0) {
+				b.len++
+			}
+		}
+		return b
+	}
+
+	// Hash contiguous chunks concurrently, bucketing by root slot. Reading
+	// the buckets chunk by chunk later preserves the input order.
+	chunks := make([][width][]hashedLeaf[K, V], workers)
+	size := (len(entries) + workers - 1) / workers
+	parallel(workers, func(w int) {
+		lo := min(w*size, len(entries))
+		hi := min(lo+size, len(entries))
+		for _, l := range entries[lo:hi] {
+			h := hashOf(hs, l.key)
+			slot := index(h, 0)
+			chunks[w][slot] = append(chunks[w][slot], hashedLeaf[K, V]{leaf: l, h: h})
+		}
+	})
+
+	// Build each root subtree on its own, starting from what the slot holds.
+	// Slots receiving no entries are left nil and keep their old contents.
+	var subs [width]*node[K, V]
+	var added atomic.Int64
+	var next atomic.Int32
+	parallel(workers, func(int) {
+		for {
+			slot := uint(next.Add(1) - 1)
+			if slot >= width {
+				return
+			}
+			if !slices.ContainsFunc(chunks, func(c [width][]hashedLeaf[K, V]) bool { return len(c[slot]) > 0 }) {
+				continue
+			}
+			bit := bitmap(1) << slot
+			sub := node[K, V]{edit: e}
+			switch {
+			case b.root.nodeMap&bit != 0:
+				sub = b.root.nodes[b.root.nodeIndex(bit)].owned(e)
+			case b.root.dataMap&bit != 0:
+				l := b.root.data[b.root.dataIndex(bit)]
+				sub.dataMap = bitpos(hashOf(hs, l.key), 1)
+				sub.data = []leaf[K, V]{l}
+			}
+
+			n := 0
+			for w := range chunks {
+				for _, l := range chunks[w][slot] {
+					if sub.insertMut(hs, e, l.key, l.val, l.h, 1) {
+						n++
+					}
+				}
+			}
+			subs[slot] = &sub
+			added.Add(int64(n))
+		}
+	})
+
+	// Stitch the subtrees into a new root, inlining singletons as insertMut
+	// would have
+	root := node[K, V]{edit: e}
+	for slot, sub := range subs {
+		bit := bitmap(1) << slot
+		switch {
+		case sub == nil && b.root.dataMap&bit != 0:
+			root.dataMap |= bit
+			root.data = append(root.data, b.root.data[b.root.dataIndex(bit)])
+		case sub == nil && b.root.nodeMap&bit != 0:
+			root.nodeMap |= bit
+			root.nodes = append(root.nodes, b.root.nodes[b.root.nodeIndex(bit)])
+		case sub == nil:
+		case sub.isSingleton():
+			root.dataMap |= bit
+			root.data = append(root.data, sub.data[0])
+		default:
+			root.nodeMap |= bit
+			root.nodes = append(root.nodes, sub)
+		}
+	}
+	b.root = root
+	b.len += int(added.Load())
+	return b
+}
+
+// parallel calls f(0), ..., f(n-1) concurrently and waits for them to return.
+func parallel(n int, f func(i int)) {
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(i)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,114 @@
+package fn
+
+import (
+	"fmt"
+	"iter"
+	"maps"
+	"math/rand/v2"
+	"testing"
+)
+
+// randomPairs yields n random pairs whose keys repeat, so later values must
+// win over earlier ones.
+func randomPairs(seed uint64, n int) iter.Seq2[int, int] {
+	return func(yield func(int, int) bool) {
+		r := rand.New(rand.NewPCG(seed, seed))
+		for i := range n {
+			if !yield(r.IntN(n), i) {
+				return
+			}
+		}
+	}
+}
+
+// checkSameTrie fails unless got and want hold the same entries in the same
+// shape.
+func checkSameTrie(t *testing.T, got, want Map[int, int]) {
+	t.Helper()
+	if got.Len() != want.Len() || got.root.count() != got.Len() {
+		t.Fatalf("expected len %d, got %d with %d in the trie", want.Len(), got.Len(), got.root.count())
+	}
+	if !got.Equal(want) {
+		t.Fatal("expected the same entries as a serial build")
+	}
+	if !got.root.equal(got.hs(), want.root, func(a, b int) bool { return a == b }, 0) {
+		t.Fatal("expected the same trie shape as a serial build")
+	}
+}
+
+func TestMapFromParallel(t *testing.T) {
+	for _, n := range []int{0, 10, parallelThreshold, 50000} {
+		for _, workers := range []int{0, 1, 3, 8} {
+			t.Run(fmt.Sprintf("n=%d/workers=%d", n, workers), func(t *testing.T) {
+				want := NewBuilder[int, int]()
+				for k, v := range randomPairs(1, n) {
+					want.Set(k, v)
+				}
+				checkSameTrie(t, MapFromParallel(randomPairs(1, n), workers), want.Build())
+			})
+		}
+	}
+}
+
+func TestBuilderSetAllExisting(t *testing.T) {
+	base := MapFromParallel(randomPairs(2, 20000), 4)
+	before := ToMap(base)
+
+	b := base.ToBuilder().Delete(-1)
+	b.Set(-1, -1)
+	got := b.SetAll(randomPairs(3, 20000), 4).Build()
+
+	want := maps.Clone(before)
+	want[-1] = -1
+	maps.Insert(want, randomPairs(3, 20000))
+	if len(want) != got.Len() {
+		t.Fatalf("expected len %d, got %d", len(want), got.Len())
+	}
+	for k, v := range want {
+		if x, ok := got.Get(k); !ok || x != v {
+			t.Fatalf("expected %d=%d, got %d, %v", k, v, x, ok)
+		}
+	}
+	if !maps.Equal(ToMap(base), before) {
+		t.Error("expected the source map to be unchanged")
+	}
+	checkSameTrie(t, got, MapFrom(want))
+}
+
+func TestBuilderSetAllCollisions(t *testing.T) {
+	for _, mask := range []uint64{0x7, 0} {
+		t.Run(fmt.Sprintf("mask=%#x", mask), func(t *testing.T) {
+			forceCollisions(t, mask)
+			want := NewBuilder[int, int]()
+			for k, v := range randomPairs(4, parallelThreshold+100) {
+				want.Set(k, v)
+			}
+			got := NewBuilder[int, int]().SetAll(randomPairs(4, parallelThreshold+100), 4).Build()
+			if !got.Equal(want.Build()) || got.root.count() != got.Len() {
+				t.Fatal("expected the same entries as a serial build")
+			}
+		})
+	}
+}
+
+func BenchmarkMapFromParallel(b *testing.B) {
+	for _, size := range []int{100000, 1000000} {
+		src := make(map[int]int, size)
+		for i := range size {
+			src[i] = i
+		}
+
+		b.Run(fmt.Sprintf("MapFrom/size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				MapFrom(src)
+			}
+		})
+		b.Run(fmt.Sprintf("MapFromParallel/size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				MapFromParallel(maps.All(src), 0)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package fn
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidPath is returned when a path passed to [SetIn], [UpdateIn] or
+// [DeleteIn] cannot be followed, because a step does not match the type of
+// the value it indexes.
+var ErrInvalidPath = errors.New("fn: invalid path")
+
+// GetIn returns the value found by following path from m through nested
+// values, or None if there is none. Each step of the path indexes the value
+// reached so far: a Map[K, any] by a key of type K, and a Vec[any] or
+// *List[any] by an int. An empty path returns m itself.
+//
+//	name := fn.GetIn(doc, "spec", "containers", 0, "name")
+func GetIn[K any](m Map[K, any], path ...any) Option[any] {
+	var cur any = m
+	for _, step := range path {
+		var ok bool
+		if cur, ok = getStep[K](cur, step); !ok {
+			return None[any]()
+		}
+	}
+	return Some(cur)
+}
+
+// getStep returns the value of c at step, if it has one.
+func getStep[K any](c, step any) (any, bool) {
+	switch c := c.(type) {
+	case Map[K, any]:
+		k, ok := step.(K)
+		if !ok {
+			return nil, false
+		}
+		return c.Get(k)
+	case Vec[any]:
+		i, ok := step.(int)
+		if !ok || i < 0 || i >= len(c) {
+			return nil, false
+		}
+		return c[i], true
+	case *List[any]:
+		i, ok := step.(int)
+		if !ok || i < 0 {
+			return nil, false
+		}
+		for ; c != nil; c = c.next {
+			if i == 0 {
+				return c.val, true
+			}
+			i--
+		}
+	}
+	return nil, false
+}
+
+// SetIn returns a copy of m with the value at path set to v, creating empty
+// maps for missing keys along the way, as [UpdateIn] does.
+//
+//	doc = fn.Unwrap(fn.SetIn(doc, 3, "spec", "replicas"))
+func SetIn[K any](m Map[K, any], v any, path ...any) Result[Map[K, any]] {
+	return UpdateIn(m, func(Option[any]) Option[any] { return Some(v) }, path...)
+}
+
+// UpdateIn returns a copy of m with the value at path replaced by the result
+// of f, as [Map.Update] does for a single key: f receives the current value
+// as Some, or None if there is none, and returning None removes it.
+//
+// Path steps are interpreted as by [GetIn]. A step that names a missing key
+// creates an empty Map[K, any] there, with m's hasher, unless f returns None.
+// An int step may index one past the end of a Vec or List to append to it.
+// Only the containers along the path are copied; every other branch is
+// shared with m. The path must not be empty, and an error wrapping
+// [ErrInvalidPath] is returned if it cannot be followed.
+func UpdateIn[K any](m Map[K, any], f func(Option[any]) Option[any], path ...any) Result[Map[K, any]] {
+	if len(path) == 0 {
+		return Err[Map[K, any]](fmt.Errorf("%w: empty path", ErrInvalidPath))
+	}
+	u := pathUpdater[K]{f: f, hasher: m.hasher, path: path}
+	v, _, err := u.update(m, true, 0)
+	if err != nil {
+		return Err[Map[K, any]](err)
+	}
+	return Ok(v.(Map[K, any]))
+}
+
+// DeleteIn returns a copy of m without the value at path, which may be an
+// entry of a nested Map or an element of a Vec or List. It returns m
+// unchanged if there is no such value.
+func DeleteIn[K any](m Map[K, any], path ...any) Map[K, any] {
+	if len(path) == 0 || !HasValue(GetIn(m, path...)) {
+		return m
+	}
+	return Unwrap(UpdateIn(m, func(Option[any]) Option[any] { return None[any]() }, path...))
+}
+
+// pathUpdater holds the state of an UpdateIn call.
+type pathUpdater[K any] struct {
+	f      func(Option[any]) Option[any]
+	hasher Hasher[K]
+	path   []any
+}
+
+// update returns the result of applying the rest of the path from step i to
+// cur, and whether it is present. If cur is absent and the update leaves
+// nothing there, cur stays absent rather than becoming an empty container.
+func (u pathUpdater[K]) update(cur any, present bool, i int) (any, bool, error) {
+	if i == len(u.path) {
+		var o Option[any]
+		if present {
+			o = Some(cur)
+		}
+		o = u.f(o)
+		return o.val, o.hasSome, nil
+	}
+
+	step := u.path[i]
+	switch c := cur.(type) {
+	case Map[K, any]:
+		return u.updateMap(c, true, step, i)
+	case Vec[any]:
+		return u.updateVec(c, step, i)
+	case *List[any]:
+		return u.updateList(c, step, i)
+	}
+	if !present {
+		if _, ok := step.(K); ok {
+			return u.updateMap(NewMapWith[K, any](u.hasher), false, step, i)
+		}
+		return nil, false, fmt.Errorf("%w: step %d: no container to index with %T", ErrInvalidPath, i, step)
+	}
+	return nil, false, fmt.Errorf("%w: step %d: cannot index %T", ErrInvalidPath, i, cur)
+}
+
+// updateMap applies the rest of the path to the entry of c at step i. If c
+// was created for the update rather than found, present is false.
+func (u pathUpdater[K]) updateMap(c Map[K, any], present bool, step any, i int) (any, bool, error) {
+	k, ok := step.(K)
+	if !ok {
+		return nil, false, fmt.Errorf("%w: step %d: cannot index a map with %T", ErrInvalidPath, i, step)
+	}
+	old, had := c.Get(k)
+	v, ok, err := u.update(old, had, i+1)
+	switch {
+	case err != nil:
+		return nil, false, err
+	case ok:
+		return c.Set(k, v), true, nil
+	case had:
+		return c.Delete(k), true, nil
+	default:
+		// Nothing was stored, so leave no created map behind
+		return c, present, nil
+	}
+}
+
+// index returns step as an index into a sequence of length n, allowing n
+// itself for appending.
+func (u pathUpdater[K]) index(step any, i, n int) (int, error) {
+	x, ok := step.(int)
+	if !ok {
+		return 0, fmt.Errorf("%w: step %d: cannot index a list with %T", ErrInvalidPath, i, step)
+	}
+	if x < 0 || x > n {
+		return 0, fmt.Errorf("%w: step %d: %w", ErrInvalidPath, i, IndexOutOfRange)
+	}
+	return x, nil
+}
+
+// updateVec applies the rest of the path to the element of c at step i.
+func (u pathUpdater[K]) updateVec(c Vec[any], step any, i int) (any, bool, error) {
+	x, err := u.index(step, i, len(c))
+	if err != nil {
+		return nil, false, err
+	}
+	var old any
+	if x < len(c) {
+		old = c[x]
+	}
+	v, ok, err := u.update(old, x < len(c), i+1)
+	switch {
+	case err != nil:
+		return nil, false, err
+	case !ok && x == len(c):
+		return c, true, nil
+	case !ok:
+		return Vec[any](removeAt(c, x)), true, nil
+	case x == len(c):
+		return append(c[:len(c):len(c)], v), true, nil
+	default:
+		return Vec[any](replaceAt(c, x, v)), true, nil
+	}
+}
+
+// updateList applies the rest of the path to the element of c at step i,
+// copying the nodes before it and sharing those after.
+func (u pathUpdater[K]) updateList(c *List[any], step any, i int) (any, bool, error) {
+	n := 0
+	for y := c; y != nil; y = y.next {
+		n++
+	}
+	x, err := u.index(step, i, n)
+	if err != nil {
+		return nil, false, err
+	}
+
+	at := c
+	for range x {
+		at = at.next
+	}
+	var old any
+	if at != nil {
+		old = at.val
+	}
+	v, ok, err := u.update(old, at != nil, i+1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var tail *List[any]
+	switch {
+	case ok && at != nil:
+		tail = &List[any]{val: v, next: at.next}
+	case ok:
+		tail = &List[any]{val: v}
+	case at != nil:
+		tail = at.next
+	default:
+		return c, true, nil
+	}
+
+	// Copy the first x nodes onto the new tail
+	head := &List[any]{}
+	last := head
+	for y := c; y != at; y = y.next {
+		last.next = &List[any]{val: y.val}
+		last = last.next
+	}
+	last.next = tail
+	return head.next, true, nil
+}
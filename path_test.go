@@ -0,0 +1,167 @@
+package fn
+
+import (
+	"errors"
+	"testing"
+)
+
+// doc builds a small nested document for path tests.
+func doc() Map[string, any] {
+	container := MapFromPairs[string, any]("name", "web", "ports", Vec[any]{80, 443})
+	return MapFromPairs[string, any](
+		"kind", "Deployment",
+		"spec", MapFromPairs[string, any](
+			"replicas", 1,
+			"containers", Vec[any]{container},
+		),
+		"tags", NewList[any]("a").Append("b").Append("c"),
+		"meta", MapFromPairs[string, any]("owner", "ops"),
+	)
+}
+
+func TestGetIn(t *testing.T) {
+	d := doc()
+	tests := []struct {
+		path []any
+		want any
+	}{
+		{[]any{"kind"}, "Deployment"},
+		{[]any{"spec", "replicas"}, 1},
+		{[]any{"spec", "containers", 0, "name"}, "web"},
+		{[]any{"spec", "containers", 0, "ports", 1}, 443},
+		{[]any{"tags", 2}, "c"},
+	}
+	for _, tt := range tests {
+		if got, ok := GetIn(d, tt.path...).unwrap(); !ok || got != tt.want {
+			t.Errorf("%v: expected %v, got %v, %v", tt.path, tt.want, got, ok)
+		}
+	}
+
+	for _, path := range [][]any{
+		{"missing"},
+		{"kind", "x"},
+		{"spec", 0},
+		{"spec", "containers", 1},
+		{"spec", "containers", -1},
+		{"spec", "containers", "0"},
+		{"tags", 3},
+	} {
+		if HasValue(GetIn(d, path...)) {
+			t.Errorf("%v: expected None", path)
+		}
+	}
+	if m := Unwrap(GetIn(d)); !m.(Map[string, any]).Equal(d) {
+		t.Error("expected an empty path to return the map")
+	}
+}
+
+func TestSetIn(t *testing.T) {
+	d := doc()
+	got := Unwrap(SetIn(d, 3, "spec", "replicas"))
+	if v := Unwrap(GetIn(got, "spec", "replicas")); v != 3 {
+		t.Errorf("expected 3 replicas, got %v", v)
+	}
+	if v := Unwrap(GetIn(d, "spec", "replicas")); v != 1 {
+		t.Error("expected the original to be unchanged")
+	}
+
+	// Untouched branches are shared, not copied
+	before, _ := d.Get("meta")
+	after, _ := got.Get("meta")
+	if !before.(Map[string, any]).root.same(after.(Map[string, any]).root) {
+		t.Error("expected untouched branches to be shared")
+	}
+
+	got = Unwrap(SetIn(got, "x", "new", "deeply", "nested"))
+	if v := Unwrap(GetIn(got, "new", "deeply", "nested")); v != "x" {
+		t.Errorf("expected intermediate maps to be created, got %v", v)
+	}
+
+	got = Unwrap(SetIn(got, 8080, "spec", "containers", 0, "ports", 2))
+	if v := Unwrap(GetIn(got, "spec", "containers", 0, "ports", 2)); v != 8080 {
+		t.Errorf("expected a vec to be appended to, got %v", v)
+	}
+	if n := len(Unwrap(GetIn(d, "spec", "containers", 0, "ports")).(Vec[any])); n != 2 {
+		t.Errorf("expected the original vec to keep 2 ports, got %d", n)
+	}
+
+	got = Unwrap(SetIn(got, "B", "tags", 1))
+	if l := Unwrap(GetIn(got, "tags")).(*List[any]); l.String() != "[a, B, c]" {
+		t.Errorf("expected [a, B, c], got %v", l)
+	}
+	if l := Unwrap(GetIn(d, "tags")).(*List[any]); l.String() != "[a, b, c]" {
+		t.Errorf("expected the original list to be unchanged, got %v", l)
+	}
+
+	folded := Unwrap(SetIn(NewMapWith[string, any](FoldHasher{}), 1, "A", "B"))
+	if !HasValue(GetIn(folded, "a", "b")) {
+		t.Error("expected created maps to keep the hasher")
+	}
+}
+
+func TestSetInErrors(t *testing.T) {
+	d := doc()
+	for _, path := range [][]any{
+		{},
+		{"kind", "x"},
+		{"spec", 0},
+		{"spec", "containers", 5},
+		{"spec", "containers", "first"},
+		{"tags", -1},
+		{"missing", 0},
+		{1.5},
+	} {
+		_, err := Unpack(SetIn(d, 1, path...))
+		if !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("%v: expected ErrInvalidPath, got %v", path, err)
+		}
+	}
+	if _, err := Unpack(SetIn(d, 1, "spec", "containers", 9)); !errors.Is(err, IndexOutOfRange) {
+		t.Errorf("expected IndexOutOfRange, got %v", err)
+	}
+}
+
+func TestUpdateIn(t *testing.T) {
+	d := doc()
+	inc := func(o Option[any]) Option[any] {
+		return Some[any](UnwrapOr(o, any(0)).(int) + 1)
+	}
+	got := Unwrap(UpdateIn(d, inc, "spec", "replicas"))
+	if v := Unwrap(GetIn(got, "spec", "replicas")); v != 2 {
+		t.Errorf("expected 2 replicas, got %v", v)
+	}
+	got = Unwrap(UpdateIn(got, inc, "stats", "restarts"))
+	if v := Unwrap(GetIn(got, "stats", "restarts")); v != 1 {
+		t.Errorf("expected a missing value to be created, got %v", v)
+	}
+
+	none := func(Option[any]) Option[any] { return None[any]() }
+	if same := Unwrap(UpdateIn(d, none, "a", "b", "c")); same.Has("a") || same.Len() != d.Len() {
+		t.Error("expected no maps to be created when nothing is stored")
+	}
+}
+
+func TestDeleteIn(t *testing.T) {
+	d := doc()
+	got := DeleteIn(d, "spec", "containers", 0, "ports", 0)
+	if ports := Unwrap(GetIn(got, "spec", "containers", 0, "ports")).(Vec[any]); len(ports) != 1 || ports[0] != 443 {
+		t.Errorf("expected [443], got %v", ports)
+	}
+	got = DeleteIn(got, "meta", "owner")
+	if m := Unwrap(GetIn(got, "meta")).(Map[string, any]); m.Len() != 0 {
+		t.Error("expected the key to be removed, leaving an empty map")
+	}
+	got = DeleteIn(got, "tags", 0)
+	if l := Unwrap(GetIn(got, "tags")).(*List[any]); l.String() != "[b, c]" {
+		t.Errorf("expected [b, c], got %v", l)
+	}
+
+	for _, path := range [][]any{{}, {"missing", "x"}, {"kind", 0}, {"tags", 7}} {
+		if same := DeleteIn(d, path...); !same.root.same(d.root) {
+			t.Errorf("%v: expected the map to be unchanged", path)
+		}
+	}
+	if Unwrap(GetIn(d, "meta", "owner")) != "ops" {
+		t.Error("expected the original to be unchanged")
+	}
+}
@@ -0,0 +1,237 @@
+package fn
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+)
+
+// quadNode is the previous 4-way trie layout, kept only as a baseline for
+// the benchmarks below. It stores a leaf at the first free node on a key's
+// path and does not handle full hash collisions.
+type quadNode[K comparable, V Val] struct {
+	leaf     *leaf[K, V]
+	children *[4]quadNode[K, V]
+}
+
+func quadIndex(h hashedKey, depth uint) uint {
+	return uint((h >> (64 - 2*(depth+1))) & 3)
+}
+
+func (n quadNode[K, V]) insert(k K, v V, h hashedKey, depth uint) quadNode[K, V] {
+	if n.leaf == nil && n.children == nil {
+		return quadNode[K, V]{leaf: &leaf[K, V]{key: k, val: v}}
+	}
+
+	x := quadNode[K, V]{leaf: n.leaf}
+	if n.children != nil {
+		c := *n.children
+		x.children = &c
+	}
+
+	if x.leaf == nil {
+		idx := quadIndex(h, depth)
+		if x.children != nil {
+			if _, ok := x.children[idx].get(k, h, depth+1); ok {
+				x.children[idx] = x.children[idx].insert(k, v, h, depth+1)
+				return x
+			}
+		}
+		x.leaf = &leaf[K, V]{key: k, val: v}
+		return x
+	}
+
+	if x.leaf.key == k {
+		x.leaf = &leaf[K, V]{key: k, val: v}
+		return x
+	}
+
+	if x.children == nil {
+		x.children = &[4]quadNode[K, V]{}
+	}
+	existingHash := hash(x.leaf.key)
+	existingIdx := quadIndex(existingHash, depth)
+	x.children[existingIdx] = x.children[existingIdx].insert(x.leaf.key, x.leaf.val, existingHash, depth+1)
+	x.leaf = nil
+
+	idx := quadIndex(h, depth)
+	x.children[idx] = x.children[idx].insert(k, v, h, depth+1)
+	return x
+}
+
+func (n quadNode[K, V]) get(k K, h hashedKey, depth uint) (V, bool) {
+	if n.leaf != nil && n.leaf.key == k {
+		return n.leaf.val, true
+	}
+	if n.children == nil {
+		var zero V
+		return zero, false
+	}
+	return n.children[quadIndex(h, depth)].get(k, h, depth+1)
+}
+
+func (n quadNode[K, V]) delete(k K, h hashedKey, depth uint) (quadNode[K, V], bool) {
+	if n.leaf != nil && n.leaf.key == k {
+		return quadNode[K, V]{children: n.children}, true
+	}
+	if n.children == nil {
+		return n, false
+	}
+	idx := quadIndex(h, depth)
+	newChild, found := n.children[idx].delete(k, h, depth+1)
+	if !found {
+		return n, false
+	}
+	x := quadNode[K, V]{leaf: n.leaf}
+	c := *n.children
+	x.children = &c
+	x.children[idx] = newChild
+	return x, true
+}
+
+func (n quadNode[K, V]) forEach(fn func(K, V) bool) bool {
+	if n.leaf != nil && !fn(n.leaf.key, n.leaf.val) {
+		return false
+	}
+	if n.children != nil {
+		for i := range n.children {
+			if !n.children[i].forEach(fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func buildQuad(size int) quadNode[int, int] {
+	var root quadNode[int, int]
+	for i := range size {
+		root = root.insert(i, i, hash(i), 0)
+	}
+	return root
+}
+
+func buildChamp(size int) node[int, int] {
+	var root node[int, int]
+	hs := defaultHasher[int]{}
+	for i := range size {
+		root = setNode(root, hs, i, i, hash(i))
+	}
+	return root
+}
+
+var layoutSizes = []int{1000, 100000, 1000000}
+
+// BenchmarkLayoutGet compares lookups in the CHAMP and 4-way layouts
+func BenchmarkLayoutGet(b *testing.B) {
+	for _, size := range layoutSizes {
+		quad, champ := buildQuad(size), buildChamp(size)
+
+		b.Run(fmt.Sprintf("Quad/size=%d", size), func(b *testing.B) {
+			for i := range b.N {
+				k := i % size
+				quad.get(k, hash(k), 0)
+			}
+		})
+		b.Run(fmt.Sprintf("Champ/size=%d", size), func(b *testing.B) {
+			for i := range b.N {
+				k := i % size
+				champ.get(defaultHasher[int]{}, k, hash(k), 0)
+			}
+		})
+	}
+}
+
+// BenchmarkLayoutSet compares path-copying updates of an existing key
+func BenchmarkLayoutSet(b *testing.B) {
+	for _, size := range layoutSizes {
+		quad, champ := buildQuad(size), buildChamp(size)
+
+		b.Run(fmt.Sprintf("Quad/size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := range b.N {
+				k := i % size
+				_ = quad.insert(k, i, hash(k), 0)
+			}
+		})
+		b.Run(fmt.Sprintf("Champ/size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := range b.N {
+				k := i % size
+				_ = setNode(champ, defaultHasher[int]{}, k, i, hash(k))
+			}
+		})
+	}
+}
+
+// BenchmarkLayoutDelete compares path-copying deletes of an existing key
+func BenchmarkLayoutDelete(b *testing.B) {
+	for _, size := range layoutSizes {
+		quad, champ := buildQuad(size), buildChamp(size)
+
+		b.Run(fmt.Sprintf("Quad/size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := range b.N {
+				k := i % size
+				_, _ = quad.delete(k, hash(k), 0)
+			}
+		})
+		b.Run(fmt.Sprintf("Champ/size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := range b.N {
+				k := i % size
+				_, _ = champ.delete(defaultHasher[int]{}, k, hash(k), 0)
+			}
+		})
+	}
+}
+
+// BenchmarkLayoutForEach compares full iteration
+func BenchmarkLayoutForEach(b *testing.B) {
+	for _, size := range layoutSizes {
+		quad, champ := buildQuad(size), buildChamp(size)
+		visit := func(int, int) bool { return true }
+
+		b.Run(fmt.Sprintf("Quad/size=%d", size), func(b *testing.B) {
+			for range b.N {
+				quad.forEach(visit)
+			}
+		})
+		b.Run(fmt.Sprintf("Champ/size=%d", size), func(b *testing.B) {
+			for range b.N {
+				champ.forEach(visit)
+			}
+		})
+	}
+}
+
+// BenchmarkLayoutMemory reports the live heap retained by each layout
+func BenchmarkLayoutMemory(b *testing.B) {
+	retained := func(build func() any) float64 {
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		v := build()
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		runtime.KeepAlive(v)
+		return float64(after.HeapAlloc - before.HeapAlloc)
+	}
+
+	for _, size := range layoutSizes {
+		b.Run(fmt.Sprintf("Quad/size=%d", size), func(b *testing.B) {
+			var bytes float64
+			for range b.N {
+				bytes = retained(func() any { return buildQuad(size) })
+			}
+			b.ReportMetric(bytes/float64(size), "B/entry")
+		})
+		b.Run(fmt.Sprintf("Champ/size=%d", size), func(b *testing.B) {
+			var bytes float64
+			for range b.N {
+				bytes = retained(func() any { return buildChamp(size) })
+			}
+			b.ReportMetric(bytes/float64(size), "B/entry")
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package fn
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Ref is a mutable reference to an immutable value, such as a [Map]
+// snapshot, that is safe for concurrent use. Readers never block: Load is a
+// single atomic read, and writers publish new values with compare-and-swap
+// instead of a lock. The zero value holds the zero value of T.
+//
+//	config := fn.NewAtomicMap(fn.NewMap[string, int]())
+//	config.Update(func(m fn.Map[string, int]) fn.Map[string, int] {
+//	    return m.Set("replicas", 3)
+//	})
+type Ref[T any] struct {
+	p    atomic.Pointer[T]
+	mu   sync.Mutex                       // serializes changes to subs
+	subs atomic.Pointer[[]*subscriber[T]] // immutable once published
+}
+
+// AtomicMap is a [Ref] holding a [Map].
+type AtomicMap[K any, V Val] = Ref[Map[K, V]]
+
+// subscriber is a registered change callback. It is a pointer so that
+// identical funcs can be told apart when canceling.
+type subscriber[T any] struct {
+	fn func(old, new T)
+}
+
+// NewRef creates a Ref holding v.
+func NewRef[T any](v T) *Ref[T] {
+	r := &Ref[T]{}
+	r.p.Store(&v)
+	return r
+}
+
+// NewAtomicMap creates an AtomicMap holding m.
+func NewAtomicMap[K any, V Val](m Map[K, V]) *AtomicMap[K, V] {
+	return NewRef(m)
+}
+
+// Load returns the current value.
+func (r *Ref[T]) Load() T {
+	return deref(r.p.Load())
+}
+
+// Store replaces the current value with v.
+func (r *Ref[T]) Store(v T) {
+	r.Swap(v)
+}
+
+// Swap replaces the current value with v and returns the previous value.
+func (r *Ref[T]) Swap(v T) T {
+	old := deref(r.p.Swap(&v))
+	r.notify(old, v)
+	return old
+}
+
+// Update replaces the current value with f applied to it and returns the new
+// value. If another goroutine changes the value while f runs, Update calls f
+// again on the newer value, so no change is ever lost; f may therefore run
+// several times and must have no side effects.
+func (r *Ref[T]) Update(f func(T) T) T {
+	for {
+		p := r.p.Load()
+		old := deref(p)
+		v := f(old)
+		if r.p.CompareAndSwap(p, &v) {
+			r.notify(old, v)
+			return v
+		}
+	}
+}
+
+// Subscribe registers fn to be called with the previous and new value after
+// every change, and returns a function that cancels the subscription.
+//
+// fn runs synchronously on the goroutine making the change, after the new
+// value is visible to Load. Every change is reported exactly once, with old
+// being precisely the value it replaced, but concurrent changes may be
+// reported concurrently and in any order. fn must not block for long, as
+// it delays the writer.
+func (r *Ref[T]) Subscribe(fn func(old, new T)) (cancel func()) {
+	s := &subscriber[T]{fn: fn}
+	r.mu.Lock()
+	subs := append(slices.Clone(deref(r.subs.Load())), s)
+	r.subs.Store(&subs)
+	r.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			subs := slices.DeleteFunc(slices.Clone(deref(r.subs.Load())), func(x *subscriber[T]) bool { return x == s })
+			r.subs.Store(&subs)
+			r.mu.Unlock()
+		})
+	}
+}
+
+// notify reports a change to every subscriber.
+func (r *Ref[T]) notify(old, v T) {
+	for _, s := range deref(r.subs.Load()) {
+		s.fn(old, v)
+	}
+}
+
+// deref returns *p, or the zero value if p is nil.
+func deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
@@ -0,0 +1,113 @@
+package fn
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRef(t *testing.T) {
+	var r Ref[int]
+	if r.Load() != 0 {
+		t.Errorf("expected the zero value, got %d", r.Load())
+	}
+	r.Store(1)
+	if old := r.Swap(2); old != 1 || r.Load() != 2 {
+		t.Errorf("expected Swap to return 1 and store 2, got %d and %d", old, r.Load())
+	}
+	if v := r.Update(func(x int) int { return x * 10 }); v != 20 || r.Load() != 20 {
+		t.Errorf("expected Update to store 20, got %d and %d", v, r.Load())
+	}
+
+	var zero Ref[string]
+	if v := zero.Update(func(s string) string { return s + "x" }); v != "x" {
+		t.Errorf("expected Update of an empty Ref to see the zero value, got %q", v)
+	}
+}
+
+func TestAtomicMapConcurrentUpdate(t *testing.T) {
+	const workers, perWorker = 8, 200
+	r := NewAtomicMap(NewMap[string, int]())
+
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perWorker {
+				r.Update(func(m Map[string, int]) Map[string, int] {
+					return m.Update("total", func(n Option[int]) Option[int] {
+						return Some(UnwrapOr(n, 0) + 1)
+					}).Set(string(rune('a'+w)), w)
+				})
+				// Readers see whole snapshots while writers run
+				if m := r.Load(); m.Len() > workers+1 {
+					t.Errorf("unexpected snapshot of len %d", m.Len())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	m := r.Load()
+	if v, _ := m.Get("total"); v != workers*perWorker {
+		t.Errorf("expected %d updates, got %d", workers*perWorker, v)
+	}
+	if m.Len() != workers+1 {
+		t.Errorf("expected len %d, got %d", workers+1, m.Len())
+	}
+}
+
+func TestRefSubscribe(t *testing.T) {
+	const workers, perWorker = 4, 100
+	r := NewRef(0)
+
+	// Every change is reported once, as an exact transition
+	var mu sync.Mutex
+	seen := make(map[int]int) // new -> old
+	cancel := r.Subscribe(func(old, v int) {
+		mu.Lock()
+		defer mu.Unlock()
+		if _, dup := seen[v]; dup {
+			t.Errorf("transition to %d reported twice", v)
+		}
+		seen[v] = old
+	})
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range perWorker {
+				r.Update(func(x int) int { return x + 1 })
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) != workers*perWorker {
+		t.Errorf("expected %d notifications, got %d", workers*perWorker, len(seen))
+	}
+	for v, old := range seen {
+		if old != v-1 {
+			t.Errorf("expected %d to replace %d, got %d", v, v-1, old)
+		}
+	}
+
+	cancel()
+	cancel()
+	r.Store(-1)
+	if _, ok := seen[-1]; ok {
+		t.Error("expected no notifications after cancel")
+	}
+
+	// Other subscribers are unaffected by a cancel
+	calls := 0
+	r.Subscribe(func(int, int) { calls++ })
+	stop := r.Subscribe(func(int, int) { t.Error("canceled subscriber called") })
+	stop()
+	r.Store(5)
+	if calls != 1 {
+		t.Errorf("expected 1 call, got %d", calls)
+	}
+}
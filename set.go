@@ -0,0 +1,197 @@
+package fn
+
+import (
+	"encoding/json"
+	"iter"
+	"slices"
+)
+
+// Set is an immutable hash set, stored in the same CHAMP trie as [Map] with
+// empty values. All operations return a new Set, leaving the original
+// unchanged. Like Map, a Set hashes and compares its elements with a
+// [Hasher], and set operations reuse subtrees the two sets share.
+type Set[T any] struct {
+	m Map[T, struct{}]
+}
+
+// NewSet creates an empty Set that hashes elements like [NewMap]. Use
+// [NewSetWith] with a [Hasher] for other element types.
+func NewSet[T comparable]() Set[T] {
+	return Set[T]{m: NewMap[T, struct{}]()}
+}
+
+// NewSetWith creates an empty Set that hashes and compares elements with h.
+func NewSetWith[T any](h Hasher[T]) Set[T] {
+	return Set[T]{m: NewMapWith[T, struct{}](h)}
+}
+
+// SetOf creates a Set holding the given elements.
+func SetOf[T comparable](items ...T) Set[T] {
+	b := NewSetBuilder[T]()
+	for _, x := range items {
+		b.Add(x)
+	}
+	return b.Build()
+}
+
+// CollectSet creates a Set holding every element of seq.
+func CollectSet[T comparable](seq iter.Seq[T]) Set[T] {
+	b := NewSetBuilder[T]()
+	for x := range seq {
+		b.Add(x)
+	}
+	return b.Build()
+}
+
+// Add returns a new Set with x added. The original Set is unchanged.
+func (s Set[T]) Add(x T) Set[T] {
+	return Set[T]{m: s.m.Set(x, struct{}{})}
+}
+
+// Remove returns a new Set without x. The original Set is unchanged.
+// Returns the same Set if x is not an element.
+func (s Set[T]) Remove(x T) Set[T] {
+	return Set[T]{m: s.m.Delete(x)}
+}
+
+// Contains returns true if x is an element of the Set.
+func (s Set[T]) Contains(x T) bool {
+	return s.m.Has(x)
+}
+
+// Len returns the number of elements in the Set.
+func (s Set[T]) Len() int {
+	return s.m.Len()
+}
+
+// All returns an iterator over every element of the Set.
+func (s Set[T]) All() iter.Seq[T] {
+	return s.m.KeysSeq()
+}
+
+// Iter implements [Iterable].
+func (s Set[T]) Iter() iter.Seq[T] {
+	return s.All()
+}
+
+var _ Iterable[int] = Set[int]{}
+
+// Union returns a Set holding the elements of both sets.
+func (s Set[T]) Union(other Set[T]) Set[T] {
+	return Set[T]{m: s.m.Union(other.m)}
+}
+
+// Intersection returns a Set holding the elements present in both sets.
+func (s Set[T]) Intersection(other Set[T]) Set[T] {
+	return Set[T]{m: s.m.Intersection(other.m)}
+}
+
+// Difference returns a Set holding the elements of s that are not in other.
+func (s Set[T]) Difference(other Set[T]) Set[T] {
+	return Set[T]{m: s.m.Difference(other.m)}
+}
+
+// SymmetricDifference returns a Set holding the elements in either set but not both.
+func (s Set[T]) SymmetricDifference(other Set[T]) Set[T] {
+	return Set[T]{m: s.m.SymmetricDifference(other.m)}
+}
+
+// IsSubset returns true if every element of s is also in other. Sets
+// sharing a hasher are compared structurally: subtrees shared by both sets
+// are skipped without being visited, and the first element of s missing
+// from other ends the comparison.
+func (s Set[T]) IsSubset(other Set[T]) bool {
+	if s.Len() > other.Len() {
+		return false
+	}
+	if sameHasher(s.m.hasher, other.m.hasher) {
+		return s.m.root.subset(s.m.hs(), other.m.root, 0)
+	}
+	for x := range s.All() {
+		if !other.Contains(x) {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal returns true if both sets hold the same elements. Like
+// [Set.IsSubset], it stops at the first difference.
+func (s Set[T]) Equal(other Set[T]) bool {
+	return s.m.EqualFunc(other.m, func(struct{}, struct{}) bool { return true })
+}
+
+// ToBuilder returns a SetBuilder seeded with the Set's elements. Edits
+// through the builder never affect s.
+func (s Set[T]) ToBuilder() *SetBuilder[T] {
+	return &SetBuilder[T]{b: s.m.ToBuilder()}
+}
+
+// MarshalJSON implements json.Marshaler for Set, encoding it as a JSON array.
+// Elements are sorted if their type is an integer, float or string, so equal
+// Sets encode identically, and are in iteration order otherwise.
+func (s Set[T]) MarshalJSON() ([]byte, error) {
+	items := make([]T, 0, s.Len())
+	for x := range s.All() {
+		items = append(items, x)
+	}
+	if isOrdered[T]() {
+		slices.SortFunc(items, compareOrdered[T])
+	}
+	return json.Marshal(items)
+}
+
+// UnmarshalJSON implements json.Unmarshaler for Set, decoding a JSON array.
+// Duplicate elements are collapsed. The Set keeps its hasher.
+func (s *Set[T]) UnmarshalJSON(data []byte) error {
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	b := NewSetBuilderWith(s.m.hasher)
+	for _, x := range items {
+		b.Add(x)
+	}
+	*s = b.Build()
+	return nil
+}
+
+// SetBuilder provides efficient mutable construction of an immutable Set.
+// After calling Build(), the SetBuilder must not be reused; doing so panics.
+type SetBuilder[T any] struct {
+	b *Builder[T, struct{}]
+}
+
+// NewSetBuilder creates a new SetBuilder for constructing a Set.
+func NewSetBuilder[T comparable]() *SetBuilder[T] {
+	return &SetBuilder[T]{b: NewBuilder[T, struct{}]()}
+}
+
+// NewSetBuilderWith creates a new SetBuilder for constructing a Set that
+// hashes and compares elements with h.
+func NewSetBuilderWith[T any](h Hasher[T]) *SetBuilder[T] {
+	return &SetBuilder[T]{b: NewBuilderWith[T, struct{}](h)}
+}
+
+// Add adds an element. Mutates the builder in place.
+func (b *SetBuilder[T]) Add(x T) *SetBuilder[T] {
+	b.b.Set(x, struct{}{})
+	return b
+}
+
+// Remove removes an element. Mutates the builder in place.
+func (b *SetBuilder[T]) Remove(x T) *SetBuilder[T] {
+	b.b.Delete(x)
+	return b
+}
+
+// Len returns the current number of elements.
+func (b *SetBuilder[T]) Len() int {
+	return b.b.Len()
+}
+
+// Build returns the constructed Set.
+// The SetBuilder must not be used after calling Build.
+func (b *SetBuilder[T]) Build() Set[T] {
+	return Set[T]{m: b.b.Build()}
+}
@@ -0,0 +1,180 @@
+package fn
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// sorted returns the elements of s in ascending order.
+func sorted(s Set[int]) []int {
+	items := slices.Collect(s.All())
+	slices.Sort(items)
+	return items
+}
+
+func TestSetBasics(t *testing.T) {
+	var s Set[int]
+	s = s.Add(1).Add(2).Add(2).Add(3)
+	if s.Len() != 3 {
+		t.Errorf("expected len 3, got %d", s.Len())
+	}
+	if !s.Contains(2) || s.Contains(4) {
+		t.Error("expected Contains to report 2 but not 4")
+	}
+
+	s2 := s.Remove(2)
+	if s2.Contains(2) || s2.Len() != 2 {
+		t.Errorf("expected 2 to be removed, got %v", sorted(s2))
+	}
+	if !s.Contains(2) {
+		t.Error("expected the original set to be unchanged")
+	}
+	if !s.Remove(9).m.root.same(s.m.root) {
+		t.Error("expected removing a missing element to return the same set")
+	}
+
+	if got := sorted(CollectSet(Range(0, 5))); !slices.Equal(got, []int{0, 1, 2, 3, 4}) {
+		t.Errorf("expected CollectSet to hold 0..4, got %v", got)
+	}
+	if got := sorted(CollectSet(Iter[int](s))); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("expected a set to be Iterable, got %v", got)
+	}
+}
+
+func TestSetAlgebra(t *testing.T) {
+	a := SetOf(1, 2, 3, 4)
+	b := SetOf(3, 4, 5)
+
+	tests := []struct {
+		name string
+		got  Set[int]
+		want []int
+	}{
+		{"Union", a.Union(b), []int{1, 2, 3, 4, 5}},
+		{"Intersection", a.Intersection(b), []int{3, 4}},
+		{"Difference", a.Difference(b), []int{1, 2}},
+		{"SymmetricDifference", a.SymmetricDifference(b), []int{1, 2, 5}},
+	}
+	for _, tt := range tests {
+		if got := sorted(tt.got); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+
+	if !SetOf(3, 4).IsSubset(a) || a.IsSubset(b) || !NewSet[int]().IsSubset(b) {
+		t.Error("IsSubset gave the wrong answer")
+	}
+	if !a.Equal(SetOf(4, 3, 2, 1)) || a.Equal(b) {
+		t.Error("Equal gave the wrong answer")
+	}
+}
+
+func TestSetIsSubset(t *testing.T) {
+	// Compare against a plain membership check, with full trie depth, with
+	// partial collisions and with every hash colliding
+	for _, m := range []uint64{ones, 0xff, 0} {
+		t.Run(fmt.Sprintf("mask %#x", m), func(t *testing.T) {
+			forceCollisions(t, m)
+			for n := range 60 {
+				base := CollectSet(Range(0, n))
+				for _, other := range []Set[int]{
+					base,
+					base.Add(1000),
+					base.Remove(n / 2),
+					base.Remove(n / 2).Add(1000),
+					CollectSet(Range(0, n)),
+					NewSet[int](),
+				} {
+					want := true
+					for x := range base.All() {
+						want = want && other.Contains(x)
+					}
+					if got := base.IsSubset(other); got != want {
+						t.Errorf("n %d: IsSubset of %v = %v, expected %v", n, sorted(other), got, want)
+					}
+					if !other.IsSubset(base.Union(other)) {
+						t.Errorf("n %d: expected %v to be a subset of the union", n, sorted(other))
+					}
+				}
+			}
+		})
+	}
+
+	// Sets with different hashers are compared by membership
+	folded := NewSetWith[string](FoldHasher{}).Add("A").Add("B")
+	if !SetOf("a").IsSubset(folded) || SetOf("a", "c").IsSubset(folded) {
+		t.Error("expected membership in other to decide mixed hashers")
+	}
+	if !folded.IsSubset(NewSetWith[string](FoldHasher{}).Add("b").Add("a")) {
+		t.Error("expected folded sets to compare case-insensitively")
+	}
+
+	big := CollectSet(Range(0, 10000))
+	sub := big.Remove(5000)
+	if n := testing.AllocsPerRun(10, func() { sub.IsSubset(big) }); n != 0 {
+		t.Errorf("expected IsSubset not to allocate, got %v allocs", n)
+	}
+}
+
+func TestSetBuilder(t *testing.T) {
+	s := SetOf("a", "b")
+	b := s.ToBuilder()
+	b.Add("c").Remove("a")
+	if b.Len() != 2 {
+		t.Errorf("expected builder len 2, got %d", b.Len())
+	}
+	got := b.Build()
+	if !got.Contains("c") || got.Contains("a") || !s.Contains("a") {
+		t.Error("expected builder edits to apply only to the built set")
+	}
+
+	folded := NewSetWith[string](FoldHasher{}).Add("Go")
+	if !folded.Contains("GO") || !folded.Add("x").Contains("gO") {
+		t.Error("expected the set to keep its hasher")
+	}
+}
+
+func TestSetJSON(t *testing.T) {
+	data, err := json.Marshal(SetOf(3, 1, 2))
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+
+	var s Set[int]
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if got := sorted(s); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("expected round trip to give [1 2 3], got %v from %s", got, data)
+	}
+
+	if err := json.Unmarshal([]byte(`[1, 1, 2]`), &s); err != nil || s.Len() != 2 {
+		t.Errorf("expected duplicates to collapse, got len %d, %v", s.Len(), err)
+	}
+	if err := json.Unmarshal([]byte(`{"a": 1}`), &s); err == nil {
+		t.Error("expected an error decoding a non-array")
+	}
+
+	// Ordered elements are sorted
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{SetOf(3, 1, 2, 5, 4, 9, 8), `[1,2,3,4,5,8,9]`},
+		{SetOf(-1.5, 2, 0.25), `[-1.5,0.25,2]`},
+		{SetOf("b", "c", "a"), `["a","b","c"]`},
+		{NewSet[string](), `[]`},
+	}
+	for _, tt := range tests {
+		if got, err := json.Marshal(tt.value); err != nil || string(got) != tt.want {
+			t.Errorf("expected %s, got %s, %v", tt.want, got, err)
+		}
+	}
+
+	folded := NewSetWith[string](FoldHasher{})
+	if err := json.Unmarshal([]byte(`["Key"]`), &folded); err != nil || !folded.Contains("KEY") {
+		t.Errorf("expected the decoded set to keep its hasher, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package fn
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// LogLimit is the largest number of entries of a Map or elements of a List
+// that their LogValue methods include. Larger containers are cut short,
+// with the number left out recorded under the key "_more".
+const LogLimit = 100
+
+// LogValue implements [slog.LogValuer], logging Some as its value and None
+// as null.
+func (o Option[T]) LogValue() slog.Value {
+	if !o.hasSome {
+		return slog.AnyValue(nil)
+	}
+	return slog.AnyValue(o.val)
+}
+
+// LogValue implements [slog.LogValuer], logging Ok as its value and Err as
+// a group holding the error message under the key "error".
+func (r Result[T]) LogValue() slog.Value {
+	if r.err != nil {
+		return slog.GroupValue(slog.String("error", r.err.Error()))
+	}
+	return slog.AnyValue(r.val)
+}
+
+// LogValue implements [slog.LogValuer], logging the Map as a group with an
+// attribute for each entry, keyed by the key printed with %v. Entries are
+// sorted as by [Map.Format] unless the Map has more than [LogLimit] entries,
+// in which case the first LogLimit in iteration order are logged, so that
+// logging a huge Map stays cheap.
+func (m Map[K, V]) LogValue() slog.Value {
+	var entries []leaf[K, V]
+	if m.len <= LogLimit {
+		entries = m.sortedEntries()
+	} else {
+		entries = make([]leaf[K, V], 0, LogLimit)
+		m.ForEach(func(k K, v V) bool {
+			if len(entries) == cap(entries) {
+				return false
+			}
+			entries = append(entries, leaf[K, V]{key: k, val: v})
+			return true
+		})
+	}
+
+	attrs := make([]slog.Attr, 0, len(entries)+1)
+	for _, e := range entries {
+		attrs = append(attrs, slog.Any(logKey(e.key), e.val))
+	}
+	if more := m.len - len(entries); more > 0 {
+		attrs = append(attrs, slog.Int("_more", more))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// LogValue implements [slog.LogValuer], logging the List as a slice of its
+// elements. A List of more than [LogLimit] elements is logged as a group
+// holding the first LogLimit under the key "items" and the number left out
+// under the key "_more".
+func (l *List[T]) LogValue() slog.Value {
+	vals := make([]any, 0)
+	n := 0
+	for y := l; y != nil; y = y.next {
+		if n < LogLimit {
+			vals = append(vals, y.val)
+		}
+		n++
+	}
+	if more := n - len(vals); more > 0 {
+		return slog.GroupValue(slog.Any("items", vals), slog.Int("_more", more))
+	}
+	return slog.AnyValue(vals)
+}
+
+// logKey returns k as an attribute key.
+func logKey[K any](k K) string {
+	if s, ok := any(k).(string); ok {
+		return s
+	}
+	return fmt.Sprint(k)
+}
+
+var (
+	_ slog.LogValuer = Option[int]{}
+	_ slog.LogValuer = Result[int]{}
+	_ slog.LogValuer = Map[int, int]{}
+	_ slog.LogValuer = (*List[int])(nil)
+)
@@ -0,0 +1,95 @@
+package fn
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// logJSON logs value under the key "v" with a JSON handler and returns the
+// logged value, decoded.
+func logJSON(t *testing.T, value any) any {
+	t.Helper()
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("msg", "v", value)
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("cannot decode %s: %v", buf.String(), err)
+	}
+	return rec["v"]
+}
+
+func TestOptionLogValue(t *testing.T) {
+	if v := logJSON(t, Some(3)); v != 3.0 {
+		t.Errorf("expected 3, got %v", v)
+	}
+	if v := logJSON(t, None[int]()); v != nil {
+		t.Errorf("expected null, got %v", v)
+	}
+}
+
+func TestResultLogValue(t *testing.T) {
+	if v := logJSON(t, Ok("done")); v != "done" {
+		t.Errorf("expected done, got %v", v)
+	}
+	v := logJSON(t, Err[int](errors.New("boom")))
+	if g, ok := v.(map[string]any); !ok || g["error"] != "boom" {
+		t.Errorf("expected an error group, got %v", v)
+	}
+}
+
+func TestMapLogValue(t *testing.T) {
+	m := MapFromPairs[string, any]("b", 2, "a", Some("x"), "c", NewMap[int, bool]().Set(7, true))
+	v := logJSON(t, m)
+	g, ok := v.(map[string]any)
+	if !ok || g["a"] != "x" || g["b"] != 2.0 || len(g) != 3 {
+		t.Fatalf("expected an attribute group, got %v", v)
+	}
+	if inner, ok := g["c"].(map[string]any); !ok || inner["7"] != true {
+		t.Errorf("expected nested maps to be groups with printed keys, got %v", g["c"])
+	}
+
+	// Text output lists entries in key order
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("msg", "m", MapFromPairs[string, int]("b", 2, "a", 1))
+	if out := buf.String(); !strings.Contains(out, "m.a=1 m.b=2") {
+		t.Errorf("expected sorted entries, got %s", out)
+	}
+}
+
+func TestMapLogValueLimit(t *testing.T) {
+	b := NewBuilder[int, int]()
+	for i := range 1000 {
+		b.Set(i, i)
+	}
+	m := b.Build()
+	g := logJSON(t, m).(map[string]any)
+	if len(g) != LogLimit+1 || g["_more"] != 900.0 {
+		t.Errorf("expected %d entries and a count of 900 more, got %d attributes and %v", LogLimit, len(g), g["_more"])
+	}
+}
+
+func TestListLogValue(t *testing.T) {
+	l := NewList(1).Append(2).Append(3)
+	if v, ok := logJSON(t, l).([]any); !ok || len(v) != 3 || v[2] != 3.0 {
+		t.Errorf("expected [1 2 3], got %v", v)
+	}
+	if v := logJSON(t, (*List[int])(nil)).([]any); len(v) != 0 {
+		t.Errorf("expected an empty list, got %v", v)
+	}
+
+	long := NewList(0)
+	for i := 1; i < LogLimit+5; i++ {
+		long = long.Append(i)
+	}
+	g, ok := logJSON(t, long).(map[string]any)
+	if !ok {
+		t.Fatalf("expected a group for a long list, got %v", g)
+	}
+	if items, _ := g["items"].([]any); len(items) != LogLimit || g["_more"] != 5.0 || len(g) != 2 {
+		t.Errorf("expected %d items and a count of 5 more, got %d items and %v", LogLimit, len(items), g["_more"])
+	}
+}
@@ -0,0 +1,109 @@
+package fn
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/bits"
+	"strings"
+	"unsafe"
+)
+
+// Stats describes the layout of a Map's trie. See [Map.Stats].
+type Stats struct {
+	Entries        int   // key-value pairs stored
+	Nodes          int   // trie nodes, including the root and collision nodes
+	CollisionNodes int   // nodes holding keys whose full hashes are equal
+	Depth          int   // deepest level holding a leaf; the root is level 0
+	LeavesByDepth  []int // LeavesByDepth[d] is the number of leaves at level d
+	NodesByDepth   []int // NodesByDepth[d] is the number of nodes at level d
+	EmptySlots     int   // unoccupied slots across all nodes but collision nodes
+	Bytes          int   // estimated bytes held by the trie itself
+}
+
+// Stats walks the trie and reports how it is laid out, for tuning and
+// diagnosing memory use. Bytes counts the nodes and their arrays at their
+// allocated capacity, but not memory that keys and values point to, such as
+// string contents, and it counts nodes shared with other Maps in full.
+func (m Map[K, V]) Stats() Stats {
+	var s Stats
+	m.root.stats(&s, 0)
+	return s
+}
+
+// stats adds n, a node at depth, and its subtree to s.
+func (n *node[K, V]) stats(s *Stats, depth int) {
+	for len(s.NodesByDepth) <= depth {
+		s.NodesByDepth = append(s.NodesByDepth, 0)
+		s.LeavesByDepth = append(s.LeavesByDepth, 0)
+	}
+	s.Nodes++
+	s.NodesByDepth[depth]++
+	s.Entries += len(n.data)
+	s.Bytes += int(unsafe.Sizeof(*n)) +
+		cap(n.data)*int(unsafe.Sizeof(leaf[K, V]{})) +
+		cap(n.nodes)*int(unsafe.Sizeof((*node[K, V])(nil)))
+
+	if depth >= maxDepth {
+		s.CollisionNodes++
+	} else {
+		s.EmptySlots += width - bits.OnesCount32(n.dataMap|n.nodeMap)
+	}
+	if len(n.data) > 0 {
+		s.LeavesByDepth[depth] += len(n.data)
+		s.Depth = max(s.Depth, depth)
+	}
+	for _, child := range n.nodes {
+		child.stats(s, depth+1)
+	}
+}
+
+// WriteDot writes the trie to w as a Graphviz DOT graph, for debugging. Each
+// node is drawn as a box listing its inline leaves by slot, with edges to its
+// sub-nodes labeled by slot. Keys and values are printed with %v, so the
+// output of a large Map is correspondingly large.
+//
+//	m.WriteDot(f) // then: dot -Tsvg trie.dot -o trie.svg
+func (m Map[K, V]) WriteDot(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph Map {\n\tnode [shape=box, fontname=monospace];\n")
+	id := 0
+	m.root.writeDot(bw, &id, 0)
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+// writeDot writes n, a node at depth, and its subtree as DOT statements,
+// numbering nodes from *id, and returns the number given to n.
+func (n *node[K, V]) writeDot(w io.Writer, id *int, depth uint) int {
+	me := *id
+	*id++
+
+	var label strings.Builder
+	if depth >= maxDepth {
+		fmt.Fprintf(&label, "collision (%d)\\l", len(n.data))
+		for _, l := range n.data {
+			fmt.Fprintf(&label, "%s\\l", dotEscaper.Replace(fmt.Sprintf("%v: %v", l.key, l.val)))
+		}
+	} else {
+		fmt.Fprintf(&label, "depth %d\\l", depth)
+		for slot := range uint(width) {
+			if bit := bitmap(1) << slot; n.dataMap&bit != 0 {
+				l := n.data[n.dataIndex(bit)]
+				fmt.Fprintf(&label, "%2d %s\\l", slot, dotEscaper.Replace(fmt.Sprintf("%v: %v", l.key, l.val)))
+			}
+		}
+	}
+	fmt.Fprintf(w, "\tn%d [label=\"%s\"];\n", me, label.String())
+
+	for slot := range uint(width) {
+		if bit := bitmap(1) << slot; n.nodeMap&bit != 0 {
+			child := n.nodes[n.nodeIndex(bit)].writeDot(w, id, depth+1)
+			fmt.Fprintf(w, "\tn%d -> n%d [label=\"%d\"];\n", me, child, slot)
+		}
+	}
+	return me
+}
+
+// dotEscaper escapes text for use inside a quoted DOT label.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
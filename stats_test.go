@@ -0,0 +1,89 @@
+package fn
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestMapStats(t *testing.T) {
+	empty := NewMap[int, int]().Stats()
+	if empty.Nodes != 1 || empty.Entries != 0 || empty.EmptySlots != width || empty.Bytes == 0 {
+		t.Errorf("expected a lone empty root, got %+v", empty)
+	}
+
+	b := NewBuilder[int, int]()
+	for i := range 10000 {
+		b.Set(i, i)
+	}
+	m := b.Build()
+	s := m.Stats()
+	if s.Entries != m.Len() {
+		t.Errorf("expected %d entries, got %d", m.Len(), s.Entries)
+	}
+	leaves, nodes := 0, 0
+	for d := range s.LeavesByDepth {
+		leaves += s.LeavesByDepth[d]
+		nodes += s.NodesByDepth[d]
+	}
+	if leaves != s.Entries || nodes != s.Nodes {
+		t.Errorf("expected the histograms to sum to %d leaves and %d nodes, got %d and %d", s.Entries, s.Nodes, leaves, nodes)
+	}
+	if s.Depth < 2 || s.Depth >= len(s.LeavesByDepth) || s.NodesByDepth[0] != 1 {
+		t.Errorf("unexpected depths %+v", s)
+	}
+	if s.CollisionNodes != 0 {
+		t.Errorf("expected no collision nodes, got %d", s.CollisionNodes)
+	}
+	if want := (s.Nodes-s.CollisionNodes)*width - s.Entries - (s.Nodes - 1); s.EmptySlots != want {
+		t.Errorf("expected %d empty slots, got %d", want, s.EmptySlots)
+	}
+	if least := s.Nodes*int(unsafe.Sizeof(node[int, int]{})) + s.Entries*16; s.Bytes < least {
+		t.Errorf("expected at least %d bytes, got %d", least, s.Bytes)
+	}
+}
+
+func TestMapStatsCollisions(t *testing.T) {
+	forceCollisions(t, 0)
+	s := MapFromPairs[int, int](1, 1, 2, 2, 3, 3).Stats()
+	if s.CollisionNodes != 1 || s.Depth != maxDepth || s.LeavesByDepth[maxDepth] != 3 || s.Entries != 3 {
+		t.Errorf("expected one collision node of 3 leaves at depth %d, got %+v", maxDepth, s)
+	}
+}
+
+func TestMapWriteDot(t *testing.T) {
+	var m Map[string, string]
+	for _, k := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n"} {
+		m = m.Set(k, k)
+	}
+	m = m.Set(`say "hi"`, "x\\y")
+
+	var buf bytes.Buffer
+	if err := m.WriteDot(&buf); err != nil {
+		t.Fatalf("WriteDot error: %v", err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "digraph Map {") || !strings.HasSuffix(out, "}\n") {
+		t.Errorf("expected a digraph, got %s", out)
+	}
+	s := m.Stats()
+	if n := strings.Count(out, "[label="); n != 2*s.Nodes-1 {
+		t.Errorf("expected %d nodes and %d edges, got %d statements", s.Nodes, s.Nodes-1, n)
+	}
+	if !strings.Contains(out, `say \"hi\": x\\y`) {
+		t.Errorf("expected labels to be escaped, got %s", out)
+	}
+}
+
+// failWriter fails every write.
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestMapWriteDotError(t *testing.T) {
+	if err := NewMap[int, int]().Set(1, 1).WriteDot(failWriter{}); err == nil {
+		t.Error("expected the write error to be returned")
+	}
+}
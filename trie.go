@@ -2,240 +2,505 @@ package fn
 
 import (
 	"bytes"
+	"encoding"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"hash/maphash"
+	"iter"
+	"math/bits"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 const (
-	bitsPerLevel = 2
-	width        = 1 << bitsPerLevel // 4 children per node
-	maxDepth     = 64 / bitsPerLevel
+	bitsPerLevel = 5
+	width        = 1 << bitsPerLevel                      // 32 slots per node
+	maxDepth     = (64 + bitsPerLevel - 1) / bitsPerLevel // levels needed to consume all 64 hash bits
 	ones         = ^uint64(0)
-	mask         = ones >> (64 - bitsPerLevel)
+	mask         = width - 1
 )
 
 var seed = maphash.MakeSeed()
 
+// hashMask is applied to every key hash. It is all ones in normal operation;
+// tests narrow it to force partial or full 64-bit hash collisions.
+var hashMask = ones
+
 type (
-	Key       = comparable
 	Val       = any
 	hashedKey = uint64
+	bitmap    = uint32
 )
 
 // leaf stores a key-value pair
-type leaf[K Key, V Val] struct {
+type leaf[K any, V Val] struct {
 	key K
 	val V
 }
 
-// children is a fixed-size array of 4 child nodes (inlined, no heap allocation)
-type children[K Key, V Val] [width]node[K, V]
+// node is a CHAMP (compressed hash-array mapped prefix tree) node. Each of
+// its 32 slots is either empty, an inline leaf, or a sub-node; the two
+// bitmaps record which, and data and nodes hold only the occupied slots in
+// slot order, so a node is never larger than its contents.
+//
+// Nodes at maxDepth have no hash bits left to branch on. They are collision
+// nodes: both bitmaps are zero and data holds every leaf sharing the full hash.
+type node[K any, V Val] struct {
+	dataMap bitmap        // slots holding an inline leaf
+	nodeMap bitmap        // slots holding a sub-node
+	data    []leaf[K, V]  // inline leaves, ordered by slot
+	nodes   []*node[K, V] // sub-nodes, ordered by slot
+	edit    *owner        // Builder allowed to mutate this node in place, if any
+}
 
-// node uses inlined children array for memory efficiency with 4-way branching.
-type node[K Key, V Val] struct {
-	leaf     *leaf[K, V]     // Optional value stored at this node
-	children *children[K, V] // Pointer to inlined children array (nil if no children)
+// owner is an ownership token identifying a single Builder. A Builder mutates
+// only nodes carrying its own token and copies any other node before touching
+// it, so nodes shared with published Maps are never modified.
+type owner struct {
+	_ byte // non-zero size so every token has a distinct address
 }
 
 // isEmpty returns true if this node has no data
 func (n node[K, V]) isEmpty() bool {
-	return n.leaf == nil && n.children == nil
+	return len(n.data) == 0 && len(n.nodes) == 0
 }
 
-// hash returns the hash of a key using maphash
-func hash[K Key](k K) hashedKey {
-	return maphash.Comparable(seed, k)
+// isSingleton returns true if this node holds exactly one leaf and no
+// sub-nodes. Such a node is never kept as a child: its parent stores the
+// leaf inline instead, which keeps the trie canonical.
+func (n node[K, V]) isSingleton() bool {
+	return len(n.nodes) == 0 && len(n.data) == 1
 }
 
-// index extracts the child index from a hash at a given depth
+// withChild returns a copy of n with the sub-node at bit, position i in
+// nodes, replaced by child after a removal below it. An emptied child is
+// dropped and a singleton child is pulled up into an inline leaf, so the
+// result has the same shape as a trie built from its entries directly.
+func (n node[K, V]) withChild(bit bitmap, i int, child node[K, V]) node[K, V] {
+	x := n
+	switch {
+	case child.isEmpty():
+		x.nodeMap &^= bit
+		x.nodes = removeAt(n.nodes, i)
+	case child.isSingleton():
+		x.nodeMap &^= bit
+		x.dataMap |= bit
+		x.nodes = removeAt(n.nodes, i)
+		x.data = insertAt(n.data, x.dataIndex(bit), child.data[0])
+	default:
+		x.nodes = replaceAt(n.nodes, i, &child)
+	}
+	return x
+}
+
+// index extracts the slot index from a hash at a given depth, consuming the
+// hash from the least significant bits up.
 func index(h hashedKey, depth uint) uint {
-	shift := 64 - bitsPerLevel*(depth+1)
-	return uint((h >> shift) & mask)
+	return uint((h >> (bitsPerLevel * depth)) & mask)
 }
 
-func (n node[K, V]) insert(k K, v V, h hashedKey, depth uint) node[K, V] {
-	// Base case: empty node, create a new leaf
-	if n.isEmpty() {
-		return node[K, V]{
-			leaf: &leaf[K, V]{key: k, val: v},
-		}
-	}
+// bitpos returns the bitmap bit for a hash at a given depth.
+func bitpos(h hashedKey, depth uint) bitmap {
+	return 1 << index(h, depth)
+}
 
-	// Copy node for immutability
-	x := node[K, V]{
-		leaf: n.leaf,
-	}
-	if n.children != nil {
-		c := *n.children
-		x.children = &c
-	}
+// dataIndex returns the position in data of the leaf at bit.
+func (n node[K, V]) dataIndex(bit bitmap) int {
+	return bits.OnesCount32(n.dataMap & (bit - 1))
+}
+
+// nodeIndex returns the position in nodes of the sub-node at bit.
+func (n node[K, V]) nodeIndex(bit bitmap) int {
+	return bits.OnesCount32(n.nodeMap & (bit - 1))
+}
 
-	// If this node has no leaf, store directly here
-	if x.leaf == nil {
-		x.leaf = &leaf[K, V]{key: k, val: v}
-		return x
+// insertAt returns a copy of s with v inserted at i.
+func insertAt[T any](s []T, i int, v T) []T {
+	out := make([]T, len(s)+1)
+	copy(out, s[:i])
+	out[i] = v
+	copy(out[i+1:], s[i:])
+	return out
+}
+
+// removeAt returns a copy of s without the element at i.
+func removeAt[T any](s []T, i int) []T {
+	if len(s) == 1 {
+		return nil
 	}
+	out := make([]T, len(s)-1)
+	copy(out, s[:i])
+	copy(out[i:], s[i+1:])
+	return out
+}
 
-	// Same key: update the value
-	if x.leaf.key == k {
-		x.leaf = &leaf[K, V]{key: k, val: v}
-		return x
+// replaceAt returns a copy of s with the element at i set to v.
+func replaceAt[T any](s []T, i int, v T) []T {
+	out := slices.Clone(s)
+	out[i] = v
+	return out
+}
+
+// pair builds the smallest subtree holding two leaves with distinct keys,
+// descending while their hashes agree and ending in a collision node if the
+// hashes are identical. The new nodes are owned by edit, which may be nil.
+func pair[K any, V Val](a leaf[K, V], ha hashedKey, b leaf[K, V], hb hashedKey, depth uint, edit *owner) node[K, V] {
+	if depth >= maxDepth {
+		return node[K, V]{data: []leaf[K, V]{a, b}, edit: edit}
 	}
 
-	// Different key: need to push existing leaf down and insert new key
-	// Ensure children array exists
-	if x.children == nil {
-		x.children = &children[K, V]{}
+	ia, ib := index(ha, depth), index(hb, depth)
+	if ia == ib {
+		sub := pair(a, ha, b, hb, depth+1, edit)
+		return node[K, V]{nodeMap: 1 << ia, nodes: []*node[K, V]{&sub}, edit: edit}
 	}
 
-	// Push existing leaf down into children
-	existingHash := hash(x.leaf.key)
-	existingIdx := index(existingHash, depth)
-	x.children[existingIdx] = x.children[existingIdx].insert(x.leaf.key, x.leaf.val, existingHash, depth+1)
-	x.leaf = nil
+	if ia > ib {
+		a, b = b, a
+	}
+	return node[K, V]{
+		dataMap: 1<<ia | 1<<ib,
+		data:    []leaf[K, V]{a, b},
+		edit:    edit,
+	}
+}
 
-	// Now insert the new key
-	idx := index(h, depth)
-	x.children[idx] = x.children[idx].insert(k, v, h, depth+1)
-	return x
+// find returns the position of k in a collision node's leaves, or -1.
+func (n node[K, V]) find(hs Hasher[K], k K) int {
+	for i := range n.data {
+		if hs.Equal(n.data[i].key, k) {
+			return i
+		}
+	}
+	return -1
 }
 
 // get retrieves a value from the trie by key
-func (n node[K, V]) get(k K, h hashedKey, depth uint) (V, bool) {
+func (n node[K, V]) get(hs Hasher[K], k K, h hashedKey, depth uint) (V, bool) {
 	var zero V
-	if n.isEmpty() {
-		return zero, false
-	}
-
-	// Check if this node's leaf matches
-	if n.leaf != nil && n.leaf.key == k {
-		return n.leaf.val, true
+	cur := &n
+	for ; depth < maxDepth; depth++ {
+		bit := bitpos(h, depth)
+		if cur.dataMap&bit != 0 {
+			l := &cur.data[cur.dataIndex(bit)]
+			if hs.Equal(l.key, k) {
+				return l.val, true
+			}
+			return zero, false
+		}
+		if cur.nodeMap&bit == 0 {
+			return zero, false
+		}
+		cur = cur.nodes[cur.nodeIndex(bit)]
 	}
 
-	// No children to search
-	if n.children == nil {
-		return zero, false
+	if i := cur.find(hs, k); i >= 0 {
+		return cur.data[i].val, true
 	}
-
-	// Recurse into the appropriate child
-	idx := index(h, depth)
-	return n.children[idx].get(k, h, depth+1)
+	return zero, false
 }
 
 // delete removes a key from the trie, returning the new trie and whether the key was found
-func (n node[K, V]) delete(k K, h hashedKey, depth uint) (node[K, V], bool) {
-	if n.isEmpty() {
-		return node[K, V]{}, false
+func (n node[K, V]) delete(hs Hasher[K], k K, h hashedKey, depth uint) (node[K, V], bool) {
+	if depth >= maxDepth {
+		i := n.find(hs, k)
+		if i < 0 {
+			return n, false
+		}
+		return node[K, V]{data: removeAt(n.data, i)}, true
 	}
 
-	// Check if this node's leaf matches
-	if n.leaf != nil && n.leaf.key == k {
-		// Found the key - remove the leaf
-		return node[K, V]{children: n.children}, true
-	}
+	bit := bitpos(h, depth)
+	x := n
 
-	// No children to search
-	if n.children == nil {
+	switch {
+	case n.dataMap&bit != 0:
+		i := n.dataIndex(bit)
+		if !hs.Equal(n.data[i].key, k) {
+			return n, false
+		}
+		x.dataMap &^= bit
+		x.data = removeAt(n.data, i)
+
+	case n.nodeMap&bit != 0:
+		i := n.nodeIndex(bit)
+		child, found := n.nodes[i].delete(hs, k, h, depth+1)
+		if !found {
+			return n, false
+		}
+		x = n.withChild(bit, i, child)
+
+	default:
 		return n, false
 	}
 
-	// Recurse into the appropriate child
-	idx := index(h, depth)
-	newChild, found := n.children[idx].delete(k, h, depth+1)
-	if !found {
-		return n, false
+	return x, true
+}
+
+// update replaces the entry for k with the result of f applied to its
+// current value, removing it if f returns None, in a single path-copying
+// pass. It returns the new trie and the change in entry count. If f leaves
+// an absent key absent, n is returned unchanged.
+func (n node[K, V]) update(hs Hasher[K], k K, f func(Option[V]) Option[V], h hashedKey, depth uint) (node[K, V], int) {
+	if depth >= maxDepth {
+		i := n.find(hs, k)
+		cur := None[V]()
+		if i >= 0 {
+			cur = Some(n.data[i].val)
+		}
+		next := f(cur)
+		switch {
+		case next.hasSome && i >= 0:
+			return node[K, V]{data: replaceAt(n.data, i, leaf[K, V]{key: k, val: next.val})}, 0
+		case next.hasSome:
+			return node[K, V]{data: append(slices.Clip(n.data), leaf[K, V]{key: k, val: next.val})}, 1
+		case i >= 0:
+			return node[K, V]{data: removeAt(n.data, i)}, -1
+		}
+		return n, 0
 	}
 
-	// Copy for immutability
-	x := node[K, V]{leaf: n.leaf}
-	c := *n.children
-	x.children = &c
-	x.children[idx] = newChild
+	bit := bitpos(h, depth)
+	x := n
 
-	return x, true
+	switch {
+	case n.dataMap&bit != 0:
+		i := n.dataIndex(bit)
+		existing := n.data[i]
+
+		if hs.Equal(existing.key, k) {
+			if next := f(Some(existing.val)); next.hasSome {
+				x.data = replaceAt(n.data, i, leaf[K, V]{key: k, val: next.val})
+				return x, 0
+			}
+			x.dataMap &^= bit
+			x.data = removeAt(n.data, i)
+			return x, -1
+		}
+
+		next := f(None[V]())
+		if !next.hasSome {
+			return n, 0
+		}
+		sub := pair(existing, hashOf(hs, existing.key), leaf[K, V]{key: k, val: next.val}, h, depth+1, nil)
+		x.dataMap &^= bit
+		x.nodeMap |= bit
+		x.data = removeAt(n.data, i)
+		x.nodes = insertAt(n.nodes, x.nodeIndex(bit), &sub)
+		return x, 1
+
+	case n.nodeMap&bit != 0:
+		i := n.nodeIndex(bit)
+		child, delta := n.nodes[i].update(hs, k, f, h, depth+1)
+		if child.same(*n.nodes[i]) {
+			return n, 0
+		}
+		if delta < 0 {
+			return n.withChild(bit, i, child), delta
+		}
+		x.nodes = replaceAt(n.nodes, i, &child)
+		return x, delta
+
+	default:
+		next := f(None[V]())
+		if !next.hasSome {
+			return n, 0
+		}
+		x.dataMap |= bit
+		x.data = insertAt(n.data, x.dataIndex(bit), leaf[K, V]{key: k, val: next.val})
+		return x, 1
+	}
 }
 
 // count returns the number of key-value pairs in the trie
 func (n node[K, V]) count() int {
-	if n.isEmpty() {
-		return 0
+	c := len(n.data)
+	for _, child := range n.nodes {
+		c += child.count()
 	}
+	return c
+}
 
-	c := 0
-	if n.leaf != nil {
-		c = 1
+// forEach calls fn for each key-value pair in the trie
+func (n node[K, V]) forEach(fn func(K, V) bool) bool {
+	for i := range n.data {
+		if !fn(n.data[i].key, n.data[i].val) {
+			return false
+		}
 	}
 
-	if n.children != nil {
-		for i := range n.children {
-			c += n.children[i].count()
+	for _, child := range n.nodes {
+		if !child.forEach(fn) {
+			return false
 		}
 	}
 
-	return c
+	return true
 }
 
-// forEach calls fn for each key-value pair in the trie
-func (n node[K, V]) forEach(fn func(K, V) bool) bool {
-	if n.isEmpty() {
+// equal reports whether n and o, nodes at the same depth of tries using hs,
+// hold the same entries, comparing values with eq. Because tries are
+// canonical, equal contents imply identical bitmaps, so any bitmap mismatch
+// settles the answer without visiting the entries.
+func (n node[K, V]) equal(hs Hasher[K], o node[K, V], eq func(a, b V) bool, depth uint) bool {
+	if n.same(o) {
 		return true
 	}
 
-	if n.leaf != nil {
-		if !fn(n.leaf.key, n.leaf.val) {
+	// Collision nodes hold their leaves in insertion order
+	if depth >= maxDepth {
+		if len(n.data) != len(o.data) {
 			return false
 		}
+		for _, l := range n.data {
+			j := o.find(hs, l.key)
+			if j < 0 || !eq(l.val, o.data[j].val) {
+				return false
+			}
+		}
+		return true
 	}
 
-	if n.children != nil {
-		for i := range n.children {
-			if !n.children[i].forEach(fn) {
+	if n.dataMap != o.dataMap || n.nodeMap != o.nodeMap {
+		return false
+	}
+	for i := range n.data {
+		if !hs.Equal(n.data[i].key, o.data[i].key) || !eq(n.data[i].val, o.data[i].val) {
+			return false
+		}
+	}
+	for i := range n.nodes {
+		if !n.nodes[i].equal(hs, *o.nodes[i], eq, depth+1) {
+			return false
+		}
+	}
+	return true
+}
+
+// subset reports whether every key of n is also a key of o, where n and o
+// are nodes at the same depth of tries using hs. Like equal, it skips
+// subtrees the tries share and stops at the first key o lacks.
+func (n node[K, V]) subset(hs Hasher[K], o node[K, V], depth uint) bool {
+	if n.same(o) {
+		return true
+	}
+
+	if depth >= maxDepth {
+		for _, l := range n.data {
+			if o.find(hs, l.key) < 0 {
 				return false
 			}
 		}
+		return true
 	}
 
+	// A sub-node holds at least two keys, which o cannot hold in a leaf
+	if n.nodeMap&^o.nodeMap != 0 {
+		return false
+	}
+	i := 0
+	for slots := n.dataMap; slots != 0; i++ {
+		bit := slots & -slots
+		slots &^= bit
+
+		k := n.data[i].key
+		switch {
+		case o.dataMap&bit != 0:
+			if !hs.Equal(k, o.data[o.dataIndex(bit)].key) {
+				return false
+			}
+		case o.nodeMap&bit != 0:
+			if _, ok := o.nodes[o.nodeIndex(bit)].get(hs, k, hashOf(hs, k), depth+1); !ok {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	i = 0
+	for slots := n.nodeMap; slots != 0; i++ {
+		bit := slots & -slots
+		slots &^= bit
+		if !n.nodes[i].subset(hs, *o.nodes[o.nodeIndex(bit)], depth+1) {
+			return false
+		}
+	}
 	return true
 }
 
-// Map is an immutable hash map using a hash array mapped trie (HAMT).
+// Map is an immutable hash map using a compressed hash array mapped trie
+// (CHAMP) with 32-way bitmap-indexed nodes.
 // All operations return a new Map, leaving the original unchanged.
-type Map[K Key, V Val] struct {
-	root node[K, V]
-	len  int
+//
+// The trie is canonical: its shape depends only on the Map's keys, not on
+// the order they were set or deleted in, and deletes compact the nodes they
+// leave behind, so a Map never holds more nodes than its entries need.
+//
+// Iteration order ([Map.ForEach], [Map.All], [Map.Keys], ...) follows key
+// hashes. With the default hasher it is randomized per process. With a
+// deterministic hasher such as [StableHasher] it is reproducible across
+// processes and machines: it depends only on the Map's keys, except for the
+// relative order of keys with identical 64-bit hashes.
+//
+// K is not constrained to be comparable, because a Map with a [Hasher] can
+// use keys that == cannot compare, such as []byte, and Go has no way to
+// require comparable keys only when no Hasher is given. Every constructor
+// that uses the default hasher ([NewMap], [NewBuilder], [MapFrom],
+// [MapFromPairs], ...) does require comparable keys, so a missing Hasher is
+// still a compile error; only the zero Map of a non-comparable key type gets
+// past the compiler, and it panics on its first Set.
+type Map[K any, V Val] struct {
+	root   node[K, V]
+	len    int
+	hasher Hasher[K] // nil means the default hasher
+}
+
+// hs returns the Map's hasher.
+func (m Map[K, V]) hs() Hasher[K] {
+	return hasherOr(m.hasher)
 }
 
 // Get retrieves a value by key. Returns the value and true if found,
 // or the zero value and false if not found.
 func (m Map[K, V]) Get(k K) (V, bool) {
-	h := hash(k)
-	return m.root.get(k, h, 0)
+	hs := m.hs()
+	return m.root.get(hs, k, hashOf(hs, k), 0)
 }
 
 // Set returns a new Map with the key-value pair added or updated.
 // The original Map is unchanged.
 func (m Map[K, V]) Set(k K, v V) Map[K, V] {
-	h := hash(k)
-	// Check if key already exists to maintain accurate length
-	_, exists := m.root.get(k, h, 0)
-	newRoot := m.root.insert(k, v, h, 0)
-	newLen := m.len
-	if !exists {
-		newLen++
-	}
-	return Map[K, V]{root: newRoot, len: newLen}
+	return m.Update(k, func(Option[V]) Option[V] { return Some(v) })
+}
+
+// Update returns a new Map in which the entry for k is replaced by the
+// result of f. f receives the current value as Some, or None if k is absent;
+// returning Some stores the value and returning None removes the key. The
+// trie is walked once, so Update is the way to read-modify-write a key:
+//
+//	counts = counts.Update(word, func(n fn.Option[int]) fn.Option[int] {
+//	    return fn.Some(fn.UnwrapOr(n, 0) + 1)
+//	})
+//
+// The original Map is unchanged.
+func (m Map[K, V]) Update(k K, f func(Option[V]) Option[V]) Map[K, V] {
+	hs := m.hs()
+	newRoot, delta := m.root.update(hs, k, f, hashOf(hs, k), 0)
+	return Map[K, V]{root: newRoot, len: m.len + delta, hasher: m.hasher}
 }
 
 // Delete returns a new Map with the key removed.
 // The original Map is unchanged. Returns the same Map if key not found.
 func (m Map[K, V]) Delete(k K) Map[K, V] {
-	h := hash(k)
-	newRoot, found := m.root.delete(k, h, 0)
+	hs := m.hs()
+	newRoot, found := m.root.delete(hs, k, hashOf(hs, k), 0)
 	if !found {
 		return m
 	}
-	return Map[K, V]{root: newRoot, len: m.len - 1}
+	return Map[K, V]{root: newRoot, len: m.len - 1, hasher: m.hasher}
 }
 
 // Len returns the number of key-value pairs in the Map.
@@ -249,6 +514,28 @@ func (m Map[K, V]) ForEach(fn func(K, V) bool) {
 	m.root.forEach(fn)
 }
 
+// All returns an iterator over every key-value pair in the Map, in the same
+// order as [Map.ForEach]. It implements [Iterable2].
+func (m Map[K, V]) All() iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		m.root.forEach(yield)
+	}
+}
+
+// KeysSeq returns a lazy iterator over the keys in the Map.
+// Unlike [Map.Keys], it does not allocate a slice.
+func (m Map[K, V]) KeysSeq() iter.Seq[K] {
+	return Keys(m.All())
+}
+
+// ValuesSeq returns a lazy iterator over the values in the Map.
+// Unlike [Map.Values], it does not allocate a slice.
+func (m Map[K, V]) ValuesSeq() iter.Seq[V] {
+	return Values(m.All())
+}
+
+var _ Iterable2[int, int] = Map[int, int]{}
+
 // Has returns true if the key exists in the Map.
 func (m Map[K, V]) Has(k K) bool {
 	_, ok := m.Get(k)
@@ -275,8 +562,11 @@ func (m Map[K, V]) Values() []V {
 	return vals
 }
 
-// ToMap returns a standard Go map with all key-value pairs.
-func (m Map[K, V]) ToMap() map[K]V {
+// ToMap returns a standard Go map with all key-value pairs of m. Keys that
+// are equal under a custom [Hasher] but not under == become separate entries.
+// It is a function rather than a method because a Go map needs comparable
+// keys, which a method cannot require of Map's K.
+func ToMap[K comparable, V Val](m Map[K, V]) map[K]V {
 	result := make(map[K]V, m.len)
 	m.ForEach(func(k K, v V) bool {
 		result[k] = v
@@ -287,14 +577,27 @@ func (m Map[K, V]) ToMap() map[K]V {
 
 // Constructors
 
-// NewMap creates an empty Map.
-func NewMap[K Key, V Val]() Map[K, V] {
-	return Map[K, V]{}
+// NewMap creates an empty Map that hashes keys with maphash and compares
+// them with ==. Use [NewMapWith] with a [Hasher] for other key types.
+func NewMap[K comparable, V Val]() Map[K, V] {
+	return Map[K, V]{hasher: defaultHasherFor[K]()}
+}
+
+// NewMapWith creates an empty Map that hashes and compares keys with h.
+// Maps derived from it, and Builders created with [Map.ToBuilder], keep h.
+func NewMapWith[K any, V Val](h Hasher[K]) Map[K, V] {
+	return Map[K, V]{hasher: h}
+}
+
+// NewStableMap creates an empty Map using a [StableHasher] with the given
+// seed, so its iteration order is the same in every process.
+func NewStableMap[K any, V Val](seed uint64) Map[K, V] {
+	return NewMapWith[K, V](StableHasher[K]{Seed: seed})
 }
 
 // MapFrom creates a Map from a standard Go map.
 // Uses mutable construction internally for efficiency.
-func MapFrom[K Key, V Val](m map[K]V) Map[K, V] {
+func MapFrom[K comparable, V Val](m map[K]V) Map[K, V] {
 	b := NewBuilder[K, V]()
 	for k, v := range m {
 		b.Set(k, v)
@@ -304,7 +607,7 @@ func MapFrom[K Key, V Val](m map[K]V) Map[K, V] {
 
 // MapFromPairs creates a Map from alternating key-value pairs.
 // Panics if an odd number of arguments is provided.
-func MapFromPairs[K Key, V Val](pairs ...any) Map[K, V] {
+func MapFromPairs[K comparable, V Val](pairs ...any) Map[K, V] {
 	if len(pairs)%2 != 0 {
 		panic("MapFromPairs requires an even number of arguments")
 	}
@@ -315,24 +618,95 @@ func MapFromPairs[K Key, V Val](pairs ...any) Map[K, V] {
 	return b.Build()
 }
 
+// ErrDuplicateKey is returned by [IndexBy] when two elements share a key.
+var ErrDuplicateKey = errors.New("fn: duplicate key")
+
+// CollectMap creates a Map from the pairs of seq. If seq yields a key more
+// than once, its last value wins.
+func CollectMap[K comparable, V Val](seq iter.Seq2[K, V]) Map[K, V] {
+	b := NewBuilder[K, V]()
+	for k, v := range seq {
+		b.Set(k, v)
+	}
+	return b.Build()
+}
+
+// GroupBy creates a Map from each key that key returns for the elements of
+// seq to the elements with that key, in the order seq yields them. Each Vec
+// is clipped to its length, so appending to it never writes to memory that
+// another copy shares.
+//
+//	byLen := fn.GroupBy(slices.Values(words), func(w string) int { return len(w) })
+func GroupBy[K comparable, T any](seq iter.Seq[T], key func(T) K) Map[K, Vec[T]] {
+	b := NewBuilder[K, Vec[T]]()
+	for x := range seq {
+		// The lists are private to the builder until Build, so they grow in place
+		b.Update(key(x), func(o Option[Vec[T]]) Option[Vec[T]] {
+			return Some(append(o.val, x))
+		})
+	}
+	return MapValues(b.Build(), func(_ K, v Vec[T]) Vec[T] { return slices.Clip(v) })
+}
+
+// IndexBy creates a Map from the key that key returns for each element of
+// seq to that element. If two elements share a key, it stops and returns an
+// error wrapping [ErrDuplicateKey].
+func IndexBy[K comparable, T any](seq iter.Seq[T], key func(T) K) Result[Map[K, T]] {
+	b := NewBuilder[K, T]()
+	for x := range seq {
+		k := key(x)
+		n := b.Len()
+		if b.Set(k, x).Len() == n {
+			return Err[Map[K, T]](fmt.Errorf("%w %v", ErrDuplicateKey, k))
+		}
+	}
+	return Ok(b.Build())
+}
+
 // Builder provides efficient mutable construction of an immutable Map.
-// After calling Build(), the Builder should not be reused.
-type Builder[K Key, V Val] struct {
-	root node[K, V]
-	len  int
+// A Builder may start empty ([NewBuilder]) or from an existing Map
+// ([Map.ToBuilder]). It copies each node the first time it modifies it and
+// then mutates its private copy, so Maps sharing those nodes never change.
+// After calling Build(), the Builder must not be reused; doing so panics.
+type Builder[K any, V Val] struct {
+	root   node[K, V]
+	len    int
+	hasher Hasher[K]
+	edit   *owner
+	built  bool
 }
 
-// NewBuilder creates a new Builder for constructing a Map.
-func NewBuilder[K Key, V Val]() *Builder[K, V] {
-	return &Builder[K, V]{}
+// NewBuilder creates a new Builder for constructing a Map that hashes keys
+// like [NewMap].
+func NewBuilder[K comparable, V Val]() *Builder[K, V] {
+	return &Builder[K, V]{hasher: defaultHasherFor[K]()}
+}
+
+// NewBuilderWith creates a new Builder for constructing a Map that hashes
+// and compares keys with h.
+func NewBuilderWith[K any, V Val](h Hasher[K]) *Builder[K, V] {
+	return &Builder[K, V]{hasher: h}
+}
+
+// own readies the builder for a mutation and returns its ownership token.
+func (b *Builder[K, V]) own() *owner {
+	if b.built {
+		panic("fn: Builder used after Build")
+	}
+	if b.edit == nil {
+		b.edit = &owner{}
+	}
+	if b.root.edit != b.edit {
+		b.root = b.root.owned(b.edit)
+	}
+	return b.edit
 }
 
 // Set adds or updates a key-value pair. Mutates the builder in place.
 func (b *Builder[K, V]) Set(k K, v V) *Builder[K, V] {
-	h := hash(k)
-	_, exists := b.root.get(k, h, 0)
-	b.root.insertMut(k, v, h, 0)
-	if !exists {
+	e := b.own()
+	hs := hasherOr(b.hasher)
+	if b.root.insertMut(hs, e, k, v, hashOf(hs, k), 0) {
 		b.len++
 	}
 	return b
@@ -340,10 +714,27 @@ func (b *Builder[K, V]) Set(k K, v V) *Builder[K, V] {
 
 // Delete removes a key. Mutates the builder in place.
 func (b *Builder[K, V]) Delete(k K) *Builder[K, V] {
-	h := hash(k)
-	if deleted := b.root.deleteMut(k, h, 0); deleted {
-		b.len--
+	if b.built {
+		panic("fn: Builder used after Build")
+	}
+	hs := hasherOr(b.hasher)
+	h := hashOf(hs, k)
+	// Look first so that deleting a missing key copies nothing
+	if _, ok := b.root.get(hs, k, h, 0); !ok {
+		return b
 	}
+	e := b.own()
+	b.root.deleteMut(hs, e, k, h, 0)
+	b.len--
+	return b
+}
+
+// Update replaces the entry for k with the result of f, as [Map.Update]
+// does. Mutates the builder in place.
+func (b *Builder[K, V]) Update(k K, f func(Option[V]) Option[V]) *Builder[K, V] {
+	e := b.own()
+	hs := hasherOr(b.hasher)
+	b.len += b.root.updateMut(hs, e, k, f, hashOf(hs, k), 0)
 	return b
 }
 
@@ -353,124 +744,282 @@ func (b *Builder[K, V]) Len() int {
 }
 
 // Build returns the constructed Map.
-// The Builder should not be used after calling Build.
+// The Builder must not be used after calling Build.
 func (b *Builder[K, V]) Build() Map[K, V] {
-	return Map[K, V]{root: b.root, len: b.len}
+	if b.built {
+		panic("fn: Builder used after Build")
+	}
+	b.built = true
+	return Map[K, V]{root: b.root, len: b.len, hasher: b.hasher}
 }
 
-// insertMut mutates the node in place (for builder use only)
-func (n *node[K, V]) insertMut(k K, v V, h hashedKey, depth uint) {
-	// Empty node - just set the leaf
-	if n.isEmpty() {
-		n.leaf = &leaf[K, V]{key: k, val: v}
-		return
-	}
+// ToBuilder returns a Builder seeded with the Map's entries. Edits through the
+// Builder copy only the nodes they touch, once each, and never affect m.
+func (m Map[K, V]) ToBuilder() *Builder[K, V] {
+	return &Builder[K, V]{root: m.root, len: m.len, hasher: m.hasher}
+}
+
+// Batch applies a group of edits to m through a [Builder] and returns the
+// resulting Map. It is much cheaper than chaining Set and Delete when making
+// many changes, since each touched node is copied at most once:
+//
+//	m = m.Batch(func(b *fn.Builder[string, int]) {
+//	    for _, k := range keys {
+//	        b.Set(k, 0)
+//	    }
+//	})
+func (m Map[K, V]) Batch(f func(*Builder[K, V])) Map[K, V] {
+	b := m.ToBuilder()
+	f(b)
+	return b.Build()
+}
 
-	// No leaf at this node - store directly
-	if n.leaf == nil {
-		n.leaf = &leaf[K, V]{key: k, val: v}
-		return
+// owned returns n if it is owned by e, or a copy of n owned by e whose
+// arrays may be mutated freely.
+func (n node[K, V]) owned(e *owner) node[K, V] {
+	if n.edit == e {
+		return n
 	}
+	return node[K, V]{
+		dataMap: n.dataMap,
+		nodeMap: n.nodeMap,
+		data:    slices.Clone(n.data),
+		nodes:   slices.Clone(n.nodes),
+		edit:    e,
+	}
+}
 
-	// Same key - update value
-	if n.leaf.key == k {
-		n.leaf = &leaf[K, V]{key: k, val: v}
-		return
+// child returns the i'th sub-node of n ready for mutation by e, replacing it
+// with an owned copy first if needed. n must already be owned by e.
+func (n *node[K, V]) child(e *owner, i int) *node[K, V] {
+	c := n.nodes[i]
+	if c.edit != e {
+		x := c.owned(e)
+		c = &x
+		n.nodes[i] = c
 	}
+	return c
+}
 
-	// Different key - push existing down and insert new
-	// Ensure children array exists
-	if n.children == nil {
-		n.children = &children[K, V]{}
+// insertMut mutates the node in place (for builder use only). n must be owned
+// by e. It reports whether k was newly added.
+func (n *node[K, V]) insertMut(hs Hasher[K], e *owner, k K, v V, h hashedKey, depth uint) bool {
+	// Hash bits are exhausted: store alongside the colliding keys
+	if depth >= maxDepth {
+		if i := n.find(hs, k); i >= 0 {
+			n.data[i].val = v
+			return false
+		}
+		n.data = append(n.data, leaf[K, V]{key: k, val: v})
+		return true
 	}
 
-	// Push existing leaf down
-	existingHash := hash(n.leaf.key)
-	existingIdx := index(existingHash, depth)
-	n.children[existingIdx].insertMut(n.leaf.key, n.leaf.val, existingHash, depth+1)
-	n.leaf = nil
+	bit := bitpos(h, depth)
+
+	switch {
+	case n.dataMap&bit != 0:
+		i := n.dataIndex(bit)
+		existing := n.data[i]
 
-	// Insert new key
-	idx := index(h, depth)
-	n.children[idx].insertMut(k, v, h, depth+1)
+		// Same key - update value
+		if hs.Equal(existing.key, k) {
+			n.data[i].val = v
+			return false
+		}
+
+		// Different key - move both leaves into a new sub-node
+		sub := pair(existing, hashOf(hs, existing.key), leaf[K, V]{key: k, val: v}, h, depth+1, e)
+		n.dataMap &^= bit
+		n.nodeMap |= bit
+		n.data = slices.Delete(n.data, i, i+1)
+		n.nodes = slices.Insert(n.nodes, n.nodeIndex(bit), &sub)
+		return true
+
+	case n.nodeMap&bit != 0:
+		return n.child(e, n.nodeIndex(bit)).insertMut(hs, e, k, v, h, depth+1)
+
+	default:
+		n.dataMap |= bit
+		n.data = slices.Insert(n.data, n.dataIndex(bit), leaf[K, V]{key: k, val: v})
+		return true
+	}
 }
 
-// deleteMut mutates the node in place (for builder use only)
-func (n *node[K, V]) deleteMut(k K, h hashedKey, depth uint) bool {
-	if n.isEmpty() {
-		return false
+// deleteMut mutates the node in place (for builder use only). n must be owned
+// by e. It reports whether k was found.
+func (n *node[K, V]) deleteMut(hs Hasher[K], e *owner, k K, h hashedKey, depth uint) bool {
+	if depth >= maxDepth {
+		i := n.find(hs, k)
+		if i < 0 {
+			return false
+		}
+		n.data = slices.Delete(n.data, i, i+1)
+		return true
 	}
 
-	// Check if this node's leaf matches
-	if n.leaf != nil && n.leaf.key == k {
-		n.leaf = nil
+	bit := bitpos(h, depth)
+
+	switch {
+	case n.dataMap&bit != 0:
+		i := n.dataIndex(bit)
+		if !hs.Equal(n.data[i].key, k) {
+			return false
+		}
+		n.dataMap &^= bit
+		n.data = slices.Delete(n.data, i, i+1)
+		return true
+
+	case n.nodeMap&bit != 0:
+		i := n.nodeIndex(bit)
+		if !n.child(e, i).deleteMut(hs, e, k, h, depth+1) {
+			return false
+		}
+		n.compactMut(bit, i)
 		return true
 	}
 
-	// No children to search
-	if n.children == nil {
-		return false
+	return false
+}
+
+// updateMut mutates the node in place (for builder use only), replacing the
+// entry for k with the result of f. n must be owned by e. It returns the
+// change in entry count.
+func (n *node[K, V]) updateMut(hs Hasher[K], e *owner, k K, f func(Option[V]) Option[V], h hashedKey, depth uint) int {
+	if depth >= maxDepth {
+		i := n.find(hs, k)
+		cur := None[V]()
+		if i >= 0 {
+			cur = Some(n.data[i].val)
+		}
+		next := f(cur)
+		switch {
+		case next.hasSome && i >= 0:
+			n.data[i].val = next.val
+			return 0
+		case next.hasSome:
+			n.data = append(n.data, leaf[K, V]{key: k, val: next.val})
+			return 1
+		case i >= 0:
+			n.data = slices.Delete(n.data, i, i+1)
+			return -1
+		}
+		return 0
+	}
+
+	bit := bitpos(h, depth)
+
+	switch {
+	case n.dataMap&bit != 0:
+		i := n.dataIndex(bit)
+		existing := n.data[i]
+
+		if hs.Equal(existing.key, k) {
+			if next := f(Some(existing.val)); next.hasSome {
+				n.data[i].val = next.val
+				return 0
+			}
+			n.dataMap &^= bit
+			n.data = slices.Delete(n.data, i, i+1)
+			return -1
+		}
+
+		next := f(None[V]())
+		if !next.hasSome {
+			return 0
+		}
+		sub := pair(existing, hashOf(hs, existing.key), leaf[K, V]{key: k, val: next.val}, h, depth+1, e)
+		n.dataMap &^= bit
+		n.nodeMap |= bit
+		n.data = slices.Delete(n.data, i, i+1)
+		n.nodes = slices.Insert(n.nodes, n.nodeIndex(bit), &sub)
+		return 1
+
+	case n.nodeMap&bit != 0:
+		i := n.nodeIndex(bit)
+		delta := n.child(e, i).updateMut(hs, e, k, f, h, depth+1)
+		if delta < 0 {
+			n.compactMut(bit, i)
+		}
+		return delta
+
+	default:
+		next := f(None[V]())
+		if !next.hasSome {
+			return 0
+		}
+		n.dataMap |= bit
+		n.data = slices.Insert(n.data, n.dataIndex(bit), leaf[K, V]{key: k, val: next.val})
+		return 1
 	}
+}
 
-	// Recurse into appropriate child
-	idx := index(h, depth)
-	return n.children[idx].deleteMut(k, h, depth+1)
+// compactMut is the in-place form of withChild, applied to the sub-node at
+// bit, position i in nodes, after a removal below it. n must be owned by the
+// mutating builder.
+func (n *node[K, V]) compactMut(bit bitmap, i int) {
+	child := n.nodes[i]
+	switch {
+	case child.isEmpty():
+		n.nodeMap &^= bit
+		n.nodes = slices.Delete(n.nodes, i, i+1)
+	case child.isSingleton():
+		n.nodeMap &^= bit
+		n.dataMap |= bit
+		n.nodes = slices.Delete(n.nodes, i, i+1)
+		n.data = slices.Insert(n.data, n.dataIndex(bit), child.data[0])
+	}
 }
 
 // Set Operations
 
 // Union returns a new Map containing all key-value pairs from both maps.
 // If a key exists in both, the value from other takes precedence.
+// Subtrees shared by both maps are reused without being visited.
 func (m Map[K, V]) Union(other Map[K, V]) Map[K, V] {
-	result := m
-	other.ForEach(func(k K, v V) bool {
-		result = result.Set(k, v)
-		return true
+	return m.merge(other, merger[K, V]{
+		onlyA:  true,
+		onlyB:  true,
+		both:   func(_ K, _, b V) (V, bool) { return b, true },
+		shared: true,
 	})
-	return result
 }
 
 // Intersection returns a new Map containing only keys present in both maps.
 // Values are taken from the receiver (m).
+// Subtrees shared by both maps are reused without being visited.
 func (m Map[K, V]) Intersection(other Map[K, V]) Map[K, V] {
-	var result Map[K, V]
-	m.ForEach(func(k K, v V) bool {
-		if other.Has(k) {
-			result = result.Set(k, v)
-		}
-		return true
-	})
-	return result
+	return m.merge(other, merger[K, V]{shared: true})
 }
 
 // Difference returns a new Map containing keys from m that are not in other.
+// Subtrees shared by both maps are dropped without being rebuilt.
 func (m Map[K, V]) Difference(other Map[K, V]) Map[K, V] {
-	result := m
-	other.ForEach(func(k K, _ V) bool {
-		result = result.Delete(k)
-		return true
+	return m.merge(other, merger[K, V]{
+		onlyA: true,
+		both:  dropBoth[K, V],
 	})
-	return result
 }
 
 // SymmetricDifference returns a new Map containing keys that are in either map but not both.
+// Subtrees shared by both maps are dropped without being rebuilt.
 func (m Map[K, V]) SymmetricDifference(other Map[K, V]) Map[K, V] {
-	var result Map[K, V]
-	// Add keys from m not in other
-	m.ForEach(func(k K, v V) bool {
-		if !other.Has(k) {
-			result = result.Set(k, v)
-		}
-		return true
+	return m.merge(other, merger[K, V]{
+		onlyA: true,
+		onlyB: true,
+		both:  dropBoth[K, V],
 	})
-	// Add keys from other not in m
-	other.ForEach(func(k K, v V) bool {
-		if !m.Has(k) {
-			result = result.Set(k, v)
-		}
-		return true
+}
+
+// MergeWith returns a new Map containing all key-value pairs from both maps,
+// calling f to resolve the value of every key present in both. Unlike
+// [Map.Union], f sees every common key, including those in shared subtrees.
+func (m Map[K, V]) MergeWith(other Map[K, V], f func(k K, a, b V) V) Map[K, V] {
+	return m.merge(other, merger[K, V]{
+		onlyA:       true,
+		onlyB:       true,
+		both:        func(k K, a, b V) (V, bool) { return f(k, a, b), true },
+		visitShared: true,
 	})
-	return result
 }
 
 // Merge returns a new Map with all entries from other added/updated.
@@ -481,7 +1030,7 @@ func (m Map[K, V]) Merge(other Map[K, V]) Map[K, V] {
 
 // Filter returns a new Map containing only entries where fn returns true.
 func (m Map[K, V]) Filter(fn func(K, V) bool) Map[K, V] {
-	var result Map[K, V]
+	result := NewMapWith[K, V](m.hasher)
 	m.ForEach(func(k K, v V) bool {
 		if fn(k, v) {
 			result = result.Set(k, v)
@@ -491,16 +1040,77 @@ func (m Map[K, V]) Filter(fn func(K, V) bool) Map[K, V] {
 	return result
 }
 
+// MapValues returns a Map with the same keys as m, each mapped to f applied
+// to its key and value. The result is built by copying m's trie node for
+// node, so no key is rehashed and the result has exactly m's shape and
+// hasher.
+func MapValues[K any, A, B Val](m Map[K, A], f func(K, A) B) Map[K, B] {
+	root, _ := mapNode(m.root, func(k K, a A) (B, error) { return f(k, a), nil })
+	return Map[K, B]{root: root, len: m.len, hasher: m.hasher}
+}
+
+// TryMapValues is like [MapValues] for a fallible f. It stops at the first
+// error f returns, in iteration order, and returns that error.
+func TryMapValues[K any, A, B Val](m Map[K, A], f func(K, A) (B, error)) Result[Map[K, B]] {
+	root, err := mapNode(m.root, f)
+	if err != nil {
+		return Err[Map[K, B]](err)
+	}
+	return Ok(Map[K, B]{root: root, len: m.len, hasher: m.hasher})
+}
+
+// mapNode returns a copy of the subtree at n with f applied to every value,
+// visiting entries in the same order as forEach.
+func mapNode[K any, A, B Val](n node[K, A], f func(K, A) (B, error)) (node[K, B], error) {
+	out := node[K, B]{dataMap: n.dataMap, nodeMap: n.nodeMap}
+	if len(n.data) > 0 {
+		out.data = make([]leaf[K, B], len(n.data))
+		for i, l := range n.data {
+			v, err := f(l.key, l.val)
+			if err != nil {
+				return out, err
+			}
+			out.data[i] = leaf[K, B]{key: l.key, val: v}
+		}
+	}
+	if len(n.nodes) > 0 {
+		out.nodes = make([]*node[K, B], len(n.nodes))
+		for i, child := range n.nodes {
+			c, err := mapNode(*child, f)
+			if err != nil {
+				return out, err
+			}
+			out.nodes[i] = &c
+		}
+	}
+	return out, nil
+}
+
 // Equal returns true if both maps have the same keys and values.
-// Values are compared using ==.
+// Values are compared with their Equal method if they have one (as
+// [time.Time] and Map do), with == if they are comparable, and with
+// [reflect.DeepEqual] otherwise, so maps of slices or maps compare safely.
+// See [Map.EqualFunc] for how the maps are walked.
 func (m Map[K, V]) Equal(other Map[K, V]) bool {
+	return m.EqualFunc(other, valuesEqual[V])
+}
+
+// EqualFunc returns true if both maps have the same keys and eq reports the
+// values of every key as equal. Maps sharing a hasher are compared
+// structurally: since equal contents give equal trie shapes, subtrees shared
+// by both maps are skipped without calling eq and the first differing slot
+// ends the comparison.
+func (m Map[K, V]) EqualFunc(other Map[K, V], eq func(a, b V) bool) bool {
 	if m.len != other.len {
 		return false
 	}
+	if sameHasher(m.hasher, other.hasher) {
+		return m.root.equal(m.hs(), other.root, eq, 0)
+	}
 	equal := true
 	m.ForEach(func(k K, v V) bool {
 		otherV, ok := other.Get(k)
-		if !ok || any(v) != any(otherV) {
+		if !ok || !eq(v, otherV) {
 			equal = false
 			return false
 		}
@@ -509,15 +1119,161 @@ func (m Map[K, V]) Equal(other Map[K, V]) bool {
 	return equal
 }
 
+// valuesEqual compares two Map values as described by [Map.Equal].
+func valuesEqual[V Val](a, b V) bool {
+	// Common value types are compared directly, without reflection.
+	switch x := any(a).(type) {
+	case string:
+		y, ok := any(b).(string)
+		return ok && x == y
+	case int:
+		y, ok := any(b).(int)
+		return ok && x == y
+	case interface{ Equal(V) bool }:
+		return x.Equal(b)
+	}
+	va, vb := reflect.ValueOf(&a).Elem(), reflect.ValueOf(&b).Elem()
+	if va.Comparable() && vb.Comparable() {
+		return va.Equal(vb)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
 // MarshalJSON implements json.Marshaler for Map.
-// Serializes as a JSON object with string keys (keys must be string-convertible).
+// Serializes as a JSON object, converting keys with the same rules
+// encoding/json uses for Go map keys: string kinds are used directly,
+// [encoding.TextMarshaler] keys are marshaled, and integer keys are
+// formatted in decimal. Keys are emitted in sorted order, as encoding/json
+// does, so the output is stable.
 func (m Map[K, V]) MarshalJSON() ([]byte, error) {
-	return json.Marshal(m.ToMap())
+	if err := checkJSONKey(reflect.TypeFor[K]()); err != nil {
+		return nil, err
+	}
+
+	type entry struct {
+		key string
+		val V
+	}
+	entries := make([]entry, 0, m.len)
+	var err error
+	m.ForEach(func(k K, v V) bool {
+		var name string
+		if name, err = jsonKey(k); err != nil {
+			return false
+		}
+		entries = append(entries, entry{key: name, val: v})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(entries, func(a, b entry) int { return strings.Compare(a.key, b.key) })
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range entries {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(e.key)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		val, err := json.Marshal(e.val)
+		if err != nil {
+			return nil, fmt.Errorf("cannot marshal value for key %q: %w", e.key, err)
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+var (
+	textMarshalerType   = reflect.TypeFor[encoding.TextMarshaler]()
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+)
+
+// checkJSONKey returns an error if encoding/json cannot use kt as an object key.
+func checkJSONKey(kt reflect.Type) error {
+	switch kt.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return nil
+	}
+	if kt.Implements(textMarshalerType) || reflect.PointerTo(kt).Implements(textUnmarshalerType) {
+		return nil
+	}
+	return fmt.Errorf("cannot use Map key type %v as a JSON object key", kt)
+}
+
+// jsonKey returns the JSON object key for k, following encoding/json.
+func jsonKey[K any](k K) (string, error) {
+	if s, ok := any(k).(string); ok {
+		return s, nil
+	}
+	rv := reflect.ValueOf(&k).Elem()
+	if rv.Kind() == reflect.String {
+		return rv.String(), nil
+	}
+	if tm, ok := any(k).(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("cannot use Map key type %v as a JSON object key", rv.Type())
+}
+
+// parseJSONKey converts a JSON object key to K, following encoding/json:
+// [encoding.TextUnmarshaler] keys are unmarshaled, string kinds are used
+// directly, and integer keys are parsed in decimal.
+func parseJSONKey[K any](name string) (K, error) {
+	var k K
+	if p, ok := any(&k).(*string); ok {
+		*p = name
+		return k, nil
+	}
+	if tu, ok := any(&k).(encoding.TextUnmarshaler); ok {
+		return k, tu.UnmarshalText([]byte(name))
+	}
+	rv := reflect.ValueOf(&k).Elem()
+	switch rv.Kind() {
+	case reflect.String:
+		rv.SetString(name)
+		return k, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(name, 10, 64)
+		if err != nil || rv.OverflowInt(n) {
+			return k, fmt.Errorf("%q is not a valid %v", name, rv.Type())
+		}
+		rv.SetInt(n)
+		return k, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, err := strconv.ParseUint(name, 10, 64)
+		if err != nil || rv.OverflowUint(n) {
+			return k, fmt.Errorf("%q is not a valid %v", name, rv.Type())
+		}
+		rv.SetUint(n)
+		return k, nil
+	}
+	return k, fmt.Errorf("cannot use Map key type %v as a JSON object key", rv.Type())
 }
 
 // UnmarshalJSON implements json.Unmarshaler for Map.
-// Decodes directly into the trie without intermediate map allocation.
+// Decodes directly into the trie without intermediate map allocation,
+// converting keys with the same rules as [Map.MarshalJSON].
 func (m *Map[K, V]) UnmarshalJSON(data []byte) error {
+	if err := checkJSONKey(reflect.TypeFor[K]()); err != nil {
+		return err
+	}
 	dec := json.NewDecoder(bytes.NewReader(data))
 
 	// Expect opening brace
@@ -529,7 +1285,7 @@ func (m *Map[K, V]) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("expected '{', got %v", tok)
 	}
 
-	b := NewBuilder[K, V]()
+	b := NewBuilderWith[K, V](m.hasher)
 
 	// Read key-value pairs
 	for dec.More() {
@@ -543,9 +1299,8 @@ func (m *Map[K, V]) UnmarshalJSON(data []byte) error {
 			return fmt.Errorf("expected string key, got %T", keyTok)
 		}
 
-		// Convert string key to K
-		var key K
-		if err := json.Unmarshal([]byte(`"`+keyStr+`"`), &key); err != nil {
+		key, err := parseJSONKey[K](keyStr)
+		if err != nil {
 			return fmt.Errorf("cannot unmarshal key %q: %w", keyStr, err)
 		}
 
@@ -0,0 +1,266 @@
+package fn
+
+// merger describes a structural set operation over two tries. Both tries are
+// walked together slot by slot, so subtrees present on only one side are
+// kept or dropped wholesale, and pointer-identical subtrees are resolved
+// without descending into them.
+type merger[K any, V Val] struct {
+	hs Hasher[K]
+
+	onlyA bool // keep entries found only in the receiver
+	onlyB bool // keep entries found only in other
+
+	// both resolves a key found on both sides, returning false to drop it.
+	// A nil both keeps the receiver's entry unchanged.
+	both func(k K, a, b V) (V, bool)
+
+	// shared is the outcome for pointer-identical subtrees: kept as-is when
+	// true, dropped when false.
+	shared bool
+
+	// visitShared disables the identical-subtree shortcut, for operations
+	// whose both func must see every common key.
+	visitShared bool
+}
+
+// dropBoth is a merger resolution that removes keys present in both maps.
+func dropBoth[K any, V Val](K, V, V) (V, bool) {
+	var zero V
+	return zero, false
+}
+
+// sameArray reports whether a and b are the same slice of the same array.
+func sameArray[T any](a, b []T) bool {
+	return len(a) == len(b) && (len(a) == 0 || &a[0] == &b[0])
+}
+
+// same reports whether n and o are the same node, sharing their arrays.
+// Nodes are never mutated once published, so same nodes hold the same entries.
+func (n node[K, V]) same(o node[K, V]) bool {
+	return n.dataMap == o.dataMap && n.nodeMap == o.nodeMap &&
+		sameArray(n.data, o.data) && sameArray(n.nodes, o.nodes)
+}
+
+// singleton returns a node at depth holding only l.
+func singleton[K any, V Val](l leaf[K, V], h hashedKey, depth uint) node[K, V] {
+	if depth >= maxDepth {
+		return node[K, V]{data: []leaf[K, V]{l}}
+	}
+	return node[K, V]{dataMap: bitpos(h, depth), data: []leaf[K, V]{l}}
+}
+
+// appendLeaf adds l at bit. Slots must be appended in ascending order.
+func (n *node[K, V]) appendLeaf(bit bitmap, l leaf[K, V]) {
+	n.dataMap |= bit
+	n.data = append(n.data, l)
+}
+
+// appendNode adds sub at bit, reusing orig when sub is the same node and
+// inlining sub when it holds a single leaf. It reports whether orig was
+// reused. Slots must be appended in ascending order.
+func (n *node[K, V]) appendNode(bit bitmap, sub node[K, V], orig *node[K, V]) bool {
+	switch {
+	case sub.isEmpty():
+		return false
+	case orig != nil && sub.same(*orig):
+		n.nodeMap |= bit
+		n.nodes = append(n.nodes, orig)
+		return true
+	case len(sub.nodes) == 0 && len(sub.data) == 1:
+		n.appendLeaf(bit, sub.data[0])
+		return false
+	}
+	n.nodeMap |= bit
+	n.nodes = append(n.nodes, &sub)
+	return false
+}
+
+// merge combines a and b, two nodes at the same depth. Besides the merged
+// node it returns how many of a's entries were dropped and how many of b's
+// were added, so the caller can derive the result's length from the inputs'
+// lengths. Subtrees kept or dropped wholesale are counted only when that
+// changes the total.
+func (mg *merger[K, V]) merge(a, b node[K, V], depth uint) (node[K, V], int, int) {
+	if !mg.visitShared && a.same(b) {
+		if mg.shared {
+			return a, 0, 0
+		}
+		return node[K, V]{}, a.count(), 0
+	}
+
+	if depth >= maxDepth {
+		return mg.mergeCollision(a, b)
+	}
+
+	var x node[K, V]
+	lost, gained := 0, 0
+	unchanged := true
+
+	for slots := a.dataMap | a.nodeMap | b.dataMap | b.nodeMap; slots != 0; {
+		bit := slots & -slots
+		slots &^= bit
+
+		aLeaf, aNode := a.dataMap&bit != 0, a.nodeMap&bit != 0
+		bLeaf, bNode := b.dataMap&bit != 0, b.nodeMap&bit != 0
+
+		switch {
+		case aLeaf && bLeaf:
+			la, lb := a.data[a.dataIndex(bit)], b.data[b.dataIndex(bit)]
+			if mg.hs.Equal(la.key, lb.key) {
+				if mg.both == nil {
+					x.appendLeaf(bit, la)
+					continue
+				}
+				unchanged = false
+				if v, ok := mg.both(la.key, la.val, lb.val); ok {
+					x.appendLeaf(bit, leaf[K, V]{key: la.key, val: v})
+				} else {
+					lost++
+				}
+				continue
+			}
+
+			// Distinct keys sharing a slot
+			switch {
+			case mg.onlyA && mg.onlyB:
+				sub := pair(la, hashOf(mg.hs, la.key), lb, hashOf(mg.hs, lb.key), depth+1, nil)
+				x.appendNode(bit, sub, nil)
+				gained++
+				unchanged = false
+			case mg.onlyA:
+				x.appendLeaf(bit, la)
+			case mg.onlyB:
+				x.appendLeaf(bit, lb)
+				lost++
+				gained++
+				unchanged = false
+			default:
+				lost++
+				unchanged = false
+			}
+
+		case aLeaf && bNode:
+			la := a.data[a.dataIndex(bit)]
+			sub, l, g := mg.merge(singleton(la, hashOf(mg.hs, la.key), depth+1), *b.nodes[b.nodeIndex(bit)], depth+1)
+			x.appendNode(bit, sub, nil)
+			lost, gained = lost+l, gained+g
+			unchanged = false
+
+		case aNode && bLeaf:
+			lb := b.data[b.dataIndex(bit)]
+			orig := a.nodes[a.nodeIndex(bit)]
+			sub, l, g := mg.merge(*orig, singleton(lb, hashOf(mg.hs, lb.key), depth+1), depth+1)
+			if !x.appendNode(bit, sub, orig) {
+				unchanged = false
+			}
+			lost, gained = lost+l, gained+g
+
+		case aNode && bNode:
+			orig := a.nodes[a.nodeIndex(bit)]
+			sub, l, g := mg.merge(*orig, *b.nodes[b.nodeIndex(bit)], depth+1)
+			if !x.appendNode(bit, sub, orig) {
+				unchanged = false
+			}
+			lost, gained = lost+l, gained+g
+
+		case aLeaf:
+			if mg.onlyA {
+				x.appendLeaf(bit, a.data[a.dataIndex(bit)])
+			} else {
+				lost++
+				unchanged = false
+			}
+
+		case aNode:
+			orig := a.nodes[a.nodeIndex(bit)]
+			if mg.onlyA {
+				x.appendNode(bit, *orig, orig)
+			} else {
+				lost += orig.count()
+				unchanged = false
+			}
+
+		case bLeaf:
+			if mg.onlyB {
+				x.appendLeaf(bit, b.data[b.dataIndex(bit)])
+				gained++
+				unchanged = false
+			}
+
+		case bNode:
+			if mg.onlyB {
+				orig := b.nodes[b.nodeIndex(bit)]
+				x.appendNode(bit, *orig, orig)
+				gained += orig.count()
+				unchanged = false
+			}
+		}
+	}
+
+	if unchanged {
+		return a, lost, gained
+	}
+	return x, lost, gained
+}
+
+// mergeCollision combines two collision nodes.
+func (mg *merger[K, V]) mergeCollision(a, b node[K, V]) (node[K, V], int, int) {
+	var x node[K, V]
+	lost, gained := 0, 0
+	unchanged := true
+
+	for _, la := range a.data {
+		j := b.find(mg.hs, la.key)
+		switch {
+		case j < 0 && mg.onlyA, j >= 0 && mg.both == nil:
+			x.data = append(x.data, la)
+		case j < 0:
+			lost++
+			unchanged = false
+		default:
+			unchanged = false
+			if v, ok := mg.both(la.key, la.val, b.data[j].val); ok {
+				x.data = append(x.data, leaf[K, V]{key: la.key, val: v})
+			} else {
+				lost++
+			}
+		}
+	}
+
+	if mg.onlyB {
+		for _, lb := range b.data {
+			if a.find(mg.hs, lb.key) < 0 {
+				x.data = append(x.data, lb)
+				gained++
+				unchanged = false
+			}
+		}
+	}
+
+	if unchanged {
+		return a, lost, gained
+	}
+	return x, lost, gained
+}
+
+// merge applies mg to m and other.
+func (m Map[K, V]) merge(other Map[K, V], mg merger[K, V]) Map[K, V] {
+	other = m.aligned(other)
+	mg.hs = m.hs()
+	root, lost, gained := mg.merge(m.root, other.root, 0)
+	return Map[K, V]{root: root, len: m.len - lost + gained, hasher: m.hasher}
+}
+
+// aligned returns other in m's hashing scheme, so that the two tries line up
+// slot by slot. If other uses a different hasher its entries are rehashed.
+func (m Map[K, V]) aligned(other Map[K, V]) Map[K, V] {
+	if sameHasher(m.hasher, other.hasher) {
+		return other
+	}
+	b := NewBuilderWith[K, V](m.hasher)
+	other.ForEach(func(k K, v V) bool {
+		b.Set(k, v)
+		return true
+	})
+	return b.Build()
+}
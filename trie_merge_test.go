@@ -0,0 +1,208 @@
+package fn
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"testing"
+)
+
+// randomMaps builds two maps that share a common ancestor, then diverge by a
+// number of random sets and deletes, mirroring snapshots derived from each other.
+func randomMaps(r *rand.Rand, size, edits int) (Map[int, int], Map[int, int]) {
+	b := NewBuilder[int, int]()
+	for range size {
+		b.Set(r.IntN(size*2), r.IntN(100))
+	}
+	base := b.Build()
+
+	diverge := func(m Map[int, int]) Map[int, int] {
+		for range edits {
+			k := r.IntN(size * 2)
+			if r.IntN(3) == 0 {
+				m = m.Delete(k)
+			} else {
+				m = m.Set(k, r.IntN(100))
+			}
+		}
+		return m
+	}
+	return diverge(base), diverge(base)
+}
+
+// checkAgainst verifies that got holds exactly the entries of want.
+func checkAgainst(t *testing.T, name string, got Map[int, int], want map[int]int) {
+	t.Helper()
+	if got.Len() != len(want) {
+		t.Errorf("%s: expected len %d, got %d", name, len(want), got.Len())
+	}
+	if c := got.root.count(); c != got.Len() {
+		t.Errorf("%s: Len %d disagrees with trie count %d", name, got.Len(), c)
+	}
+	for k, v := range want {
+		if gv, ok := got.Get(k); !ok || gv != v {
+			t.Errorf("%s: key %d expected %d, got %d, %v", name, k, v, gv, ok)
+		}
+	}
+}
+
+func testSetOperations(t *testing.T, a, b Map[int, int]) {
+	t.Helper()
+	am, bm := ToMap(a), ToMap(b)
+
+	union := make(map[int]int)
+	inter := make(map[int]int)
+	diff := make(map[int]int)
+	sym := make(map[int]int)
+	sum := make(map[int]int)
+	for k, v := range am {
+		union[k] = v
+		sum[k] = v
+		if _, ok := bm[k]; ok {
+			inter[k] = v
+		} else {
+			diff[k] = v
+			sym[k] = v
+		}
+	}
+	for k, v := range bm {
+		union[k] = v
+		sum[k] += v
+		if _, ok := am[k]; !ok {
+			sym[k] = v
+		}
+	}
+
+	checkAgainst(t, "Union", a.Union(b), union)
+	checkAgainst(t, "Intersection", a.Intersection(b), inter)
+	checkAgainst(t, "Difference", a.Difference(b), diff)
+	checkAgainst(t, "SymmetricDifference", a.SymmetricDifference(b), sym)
+	checkAgainst(t, "MergeWith", a.MergeWith(b, func(_ int, x, y int) int { return x + y }), sum)
+}
+
+func TestSetOperationsRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	for _, size := range []int{0, 1, 10, 100, 5000} {
+		for _, edits := range []int{0, 1, 10, 500} {
+			t.Run(fmt.Sprintf("size=%d/edits=%d", size, edits), func(t *testing.T) {
+				a, b := randomMaps(r, max(size, 1), edits)
+				if size == 0 {
+					a = NewMap[int, int]()
+				}
+				testSetOperations(t, a, b)
+				testSetOperations(t, b, a)
+				testSetOperations(t, a, a)
+				testSetOperations(t, a, NewMap[int, int]())
+				testSetOperations(t, NewMap[int, int](), a)
+			})
+		}
+	}
+}
+
+func TestSetOperationsRandomCollisions(t *testing.T) {
+	forceCollisions(t, 0x3f)
+
+	r := rand.New(rand.NewPCG(3, 4))
+	for _, edits := range []int{0, 5, 50} {
+		a, b := randomMaps(r, 200, edits)
+		testSetOperations(t, a, b)
+		testSetOperations(t, b, a)
+	}
+}
+
+func TestSetOperationsShareStructure(t *testing.T) {
+	var m Map[int, int]
+	for i := range 10000 {
+		m = m.Set(i, i)
+	}
+	edited := m.Set(42, -1)
+
+	// Identical maps short-circuit at the root
+	if u := m.Union(m); !u.root.same(m.root) {
+		t.Error("Union of a map with itself should return the same trie")
+	}
+	if i := m.Intersection(m); !i.root.same(m.root) {
+		t.Error("Intersection of a map with itself should return the same trie")
+	}
+	if d := m.Difference(m); d.Len() != 0 || !d.root.isEmpty() {
+		t.Errorf("Difference of a map with itself should be empty, got len %d", d.Len())
+	}
+
+	// Derived maps reuse every subtree off the edited path
+	u := m.Union(edited)
+	if v, _ := u.Get(42); v != -1 {
+		t.Errorf("expected 42=-1, got %d", v)
+	}
+	shared := 0
+	for i, child := range u.root.nodes {
+		if child == m.root.nodes[i] {
+			shared++
+		}
+	}
+	if shared != len(m.root.nodes)-1 {
+		t.Errorf("expected %d shared root children, got %d", len(m.root.nodes)-1, shared)
+	}
+
+	if d := edited.Set(-5, 5).Difference(m); d.Len() != 1 || !d.Has(-5) {
+		t.Errorf("expected difference to hold only key -5, got len %d", d.Len())
+	}
+	if s := edited.SymmetricDifference(m); s.Len() != 0 {
+		t.Errorf("expected empty symmetric difference, got len %d", s.Len())
+	}
+}
+
+func TestMergeWith(t *testing.T) {
+	m1 := NewMap[string, int]().Set("a", 1).Set("b", 2)
+	m2 := NewMap[string, int]().Set("b", 20).Set("c", 3)
+
+	var conflicts []string
+	result := m1.MergeWith(m2, func(k string, a, b int) int {
+		conflicts = append(conflicts, k)
+		return a + b
+	})
+
+	if result.Len() != 3 {
+		t.Errorf("expected len 3, got %d", result.Len())
+	}
+	if v, _ := result.Get("b"); v != 22 {
+		t.Errorf("expected b=22, got %d", v)
+	}
+	if len(conflicts) != 1 || conflicts[0] != "b" {
+		t.Errorf("expected a single conflict on 'b', got %v", conflicts)
+	}
+
+	// Shared subtrees still see every common key
+	counts := MapFromPairs[string, int]("x", 1, "y", 2)
+	doubled := counts.MergeWith(counts, func(_ string, a, b int) int { return a + b })
+	if v, _ := doubled.Get("x"); v != 2 {
+		t.Errorf("expected x=2, got %d", v)
+	}
+	if v, _ := doubled.Get("y"); v != 4 {
+		t.Errorf("expected y=4, got %d", v)
+	}
+}
+
+// BenchmarkSetOperationsDerived measures set operations between a large map
+// and a copy with a handful of edits
+func BenchmarkSetOperationsDerived(b *testing.B) {
+	for _, size := range sizes {
+		bld := NewBuilder[int, int]()
+		for i := range size {
+			bld.Set(i, i)
+		}
+		m := bld.Build()
+		edited := m.Set(0, -1).Delete(1).Set(size, size)
+
+		b.Run(fmt.Sprintf("Union/size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				_ = m.Union(edited)
+			}
+		})
+		b.Run(fmt.Sprintf("Difference/size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				_ = edited.Difference(m)
+			}
+		})
+	}
+}
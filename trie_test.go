@@ -2,19 +2,38 @@ package fn
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
+// hash returns the hash of a key using the default hasher.
+func hash[K any](k K) hashedKey {
+	return hashOf(defaultHasher[K]{}, k)
+}
+
+// setNode sets k to v in the trie rooted at n through update, the write
+// path behind Map.Set.
+func setNode[K any, V Val](n node[K, V], hs Hasher[K], k K, v V, h hashedKey) node[K, V] {
+	n, _ = n.update(hs, k, func(Option[V]) Option[V] { return Some(v) }, h, 0)
+	return n
+}
+
 func TestInsertAndGet(t *testing.T) {
 	var root node[string, int]
+	hs := defaultHasher[string]{}
 
 	// Insert a single key
 	h := hash("hello")
-	root = root.insert("hello", 42, h, 0)
+	root = setNode(root, hs, "hello", 42, h)
 
 	// Retrieve it
-	val, ok := root.get("hello", h, 0)
+	val, ok := root.get(hs, "hello", h, 0)
 	if !ok {
 		t.Fatal("expected to find 'hello'")
 	}
@@ -24,7 +43,7 @@ func TestInsertAndGet(t *testing.T) {
 
 	// Key not found
 	h2 := hash("world")
-	_, ok = root.get("world", h2, 0)
+	_, ok = root.get(hs, "world", h2, 0)
 	if ok {
 		t.Error("expected 'world' to not be found")
 	}
@@ -32,12 +51,13 @@ func TestInsertAndGet(t *testing.T) {
 
 func TestInsertOverwrite(t *testing.T) {
 	var root node[string, int]
+	hs := defaultHasher[string]{}
 
 	h := hash("key")
-	root = root.insert("key", 1, h, 0)
-	root = root.insert("key", 2, h, 0)
+	root = setNode(root, hs, "key", 1, h)
+	root = setNode(root, hs, "key", 2, h)
 
-	val, ok := root.get("key", h, 0)
+	val, ok := root.get(hs, "key", h, 0)
 	if !ok {
 		t.Fatal("expected to find 'key'")
 	}
@@ -48,31 +68,32 @@ func TestInsertOverwrite(t *testing.T) {
 
 func TestImmutability(t *testing.T) {
 	var root node[string, int]
+	hs := defaultHasher[string]{}
 
 	h1 := hash("a")
-	root1 := root.insert("a", 1, h1, 0)
+	root1 := setNode(root, hs, "a", 1, h1)
 
 	h2 := hash("b")
-	root2 := root1.insert("b", 2, h2, 0)
+	root2 := setNode(root1, hs, "b", 2, h2)
 
 	// root1 should still only have "a"
-	val, ok := root1.get("a", h1, 0)
+	val, ok := root1.get(hs, "a", h1, 0)
 	if !ok || val != 1 {
 		t.Error("root1 should have 'a' = 1")
 	}
 
-	_, ok = root1.get("b", h2, 0)
+	_, ok = root1.get(hs, "b", h2, 0)
 	if ok {
 		t.Error("root1 should NOT have 'b' (immutability violated)")
 	}
 
 	// root2 should have both
-	val, ok = root2.get("a", h1, 0)
+	val, ok = root2.get(hs, "a", h1, 0)
 	if !ok || val != 1 {
 		t.Error("root2 should have 'a' = 1")
 	}
 
-	val, ok = root2.get("b", h2, 0)
+	val, ok = root2.get(hs, "b", h2, 0)
 	if !ok || val != 2 {
 		t.Error("root2 should have 'b' = 2")
 	}
@@ -80,18 +101,19 @@ func TestImmutability(t *testing.T) {
 
 func TestMultipleInserts(t *testing.T) {
 	var root node[string, int]
+	hs := defaultHasher[string]{}
 
 	keys := []string{"apple", "banana", "cherry", "date", "elderberry", "fig", "grape"}
 
 	for i, k := range keys {
 		h := hash(k)
-		root = root.insert(k, i, h, 0)
+		root = setNode(root, hs, k, i, h)
 	}
 
 	// Verify all keys are retrievable
 	for i, k := range keys {
 		h := hash(k)
-		val, ok := root.get(k, h, 0)
+		val, ok := root.get(hs, k, h, 0)
 		if !ok {
 			t.Errorf("expected to find key %q", k)
 			continue
@@ -104,17 +126,18 @@ func TestMultipleInserts(t *testing.T) {
 
 func TestManyKeys(t *testing.T) {
 	var root node[int, int]
+	hs := defaultHasher[int]{}
 
 	n := 1000
 	for i := range n {
 		h := hash(i)
-		root = root.insert(i, i*10, h, 0)
+		root = setNode(root, hs, i, i*10, h)
 	}
 
 	// Verify all keys
 	for i := range n {
 		h := hash(i)
-		val, ok := root.get(i, h, 0)
+		val, ok := root.get(hs, i, h, 0)
 		if !ok {
 			t.Errorf("expected to find key %d", i)
 			continue
@@ -127,7 +150,7 @@ func TestManyKeys(t *testing.T) {
 	// Verify missing keys
 	for i := n; i < n+100; i++ {
 		h := hash(i)
-		_, ok := root.get(i, h, 0)
+		_, ok := root.get(hs, i, h, 0)
 		if ok {
 			t.Errorf("key %d should not exist", i)
 		}
@@ -137,18 +160,19 @@ func TestManyKeys(t *testing.T) {
 func TestHashCollisionHandling(t *testing.T) {
 	// Insert many keys that may have partial hash collisions
 	var root node[string, int]
+	hs := defaultHasher[string]{}
 
 	for i := range 100 {
 		k := fmt.Sprintf("key%d", i)
 		h := hash(k)
-		root = root.insert(k, i, h, 0)
+		root = setNode(root, hs, k, i, h)
 	}
 
 	// All should be retrievable
 	for i := range 100 {
 		k := fmt.Sprintf("key%d", i)
 		h := hash(k)
-		val, ok := root.get(k, h, 0)
+		val, ok := root.get(hs, k, h, 0)
 		if !ok {
 			t.Errorf("expected to find %q", k)
 			continue
@@ -159,6 +183,195 @@ func TestHashCollisionHandling(t *testing.T) {
 	}
 }
 
+// forceCollisions narrows the hash to the given bits for the duration of the
+// test, so distinct keys share some or all of their 64-bit hash.
+func forceCollisions(t *testing.T, m uint64) {
+	t.Helper()
+	old := hashMask
+	hashMask = m
+	t.Cleanup(func() { hashMask = old })
+}
+
+func TestFullHashCollision(t *testing.T) {
+	forceCollisions(t, 0)
+
+	var m Map[string, int]
+	for i := range 20 {
+		m = m.Set(fmt.Sprintf("k%d", i), i)
+	}
+	if m.Len() != 20 {
+		t.Fatalf("expected len 20, got %d", m.Len())
+	}
+	if c := m.root.count(); c != 20 {
+		t.Errorf("expected count 20, got %d", c)
+	}
+
+	for i := range 20 {
+		v, ok := m.Get(fmt.Sprintf("k%d", i))
+		if !ok || v != i {
+			t.Errorf("k%d: expected %d, got %d, %v", i, i, v, ok)
+		}
+	}
+	if m.Has("missing") {
+		t.Error("expected 'missing' to not be found")
+	}
+
+	// Overwrite inside a collision node
+	m2 := m.Set("k3", 300)
+	if v, _ := m2.Get("k3"); v != 300 {
+		t.Errorf("expected k3=300, got %d", v)
+	}
+	if v, _ := m.Get("k3"); v != 3 {
+		t.Errorf("original should still have k3=3, got %d", v)
+	}
+	if m2.Len() != 20 || m2.root.count() != 20 {
+		t.Errorf("expected len 20 after overwrite, got %d (count %d)", m2.Len(), m2.root.count())
+	}
+
+	// Delete every key, checking the rest remain reachable
+	d := m
+	for i := range 20 {
+		d = d.Delete(fmt.Sprintf("k%d", i))
+		if d.Len() != 19-i {
+			t.Fatalf("expected len %d, got %d", 19-i, d.Len())
+		}
+		for j := i + 1; j < 20; j++ {
+			if !d.Has(fmt.Sprintf("k%d", j)) {
+				t.Fatalf("k%d lost after deleting k%d", j, i)
+			}
+		}
+	}
+	if c := d.root.count(); c != 0 {
+		t.Errorf("expected empty trie after deleting every key, got %d", c)
+	}
+	if m.Len() != 20 || !m.Has("k0") {
+		t.Error("original should be unchanged by deletes")
+	}
+	if d2 := m.Delete("missing"); d2.Len() != 20 {
+		t.Errorf("delete of missing colliding key should not change len")
+	}
+}
+
+func TestPartialHashCollision(t *testing.T) {
+	// Only the lowest bits vary, so keys share long hash prefixes and some
+	// share the full hash.
+	forceCollisions(t, 0x7)
+
+	var m Map[int, int]
+	for i := range 200 {
+		m = m.Set(i, i*10)
+	}
+	if m.Len() != 200 {
+		t.Fatalf("expected len 200, got %d", m.Len())
+	}
+	for i := range 200 {
+		if v, ok := m.Get(i); !ok || v != i*10 {
+			t.Errorf("key %d: expected %d, got %d", i, i*10, v)
+		}
+	}
+
+	seen := 0
+	m.ForEach(func(k, v int) bool {
+		if v != k*10 {
+			t.Errorf("ForEach: key %d has %d", k, v)
+		}
+		seen++
+		return true
+	})
+	if seen != 200 {
+		t.Errorf("expected ForEach to visit 200 keys, got %d", seen)
+	}
+
+	for i := 0; i < 200; i += 2 {
+		m = m.Delete(i)
+	}
+	if m.Len() != 100 {
+		t.Errorf("expected len 100 after deletes, got %d", m.Len())
+	}
+	for i := range 200 {
+		if m.Has(i) != (i%2 == 1) {
+			t.Errorf("key %d: unexpected presence %v", i, m.Has(i))
+		}
+	}
+}
+
+func TestCollisionForEachEarlyStop(t *testing.T) {
+	forceCollisions(t, 0)
+
+	var m Map[int, int]
+	for i := range 10 {
+		m = m.Set(i, i)
+	}
+
+	count := 0
+	m.ForEach(func(k, v int) bool {
+		count++
+		return count < 3
+	})
+	if count != 3 {
+		t.Errorf("expected ForEach to stop after 3, got %d", count)
+	}
+}
+
+func TestBuilderCollision(t *testing.T) {
+	forceCollisions(t, 0)
+
+	b := NewBuilder[string, int]()
+	for i := range 10 {
+		b.Set(fmt.Sprintf("k%d", i), i)
+	}
+	b.Set("k4", 40)
+	b.Delete("k7").Delete("missing")
+	if b.Len() != 9 {
+		t.Errorf("expected len 9, got %d", b.Len())
+	}
+
+	m := b.Build()
+	if v, _ := m.Get("k4"); v != 40 {
+		t.Errorf("expected k4=40, got %d", v)
+	}
+	if m.Has("k7") {
+		t.Error("expected 'k7' to be deleted")
+	}
+	if c := m.root.count(); c != 9 {
+		t.Errorf("expected count 9, got %d", c)
+	}
+}
+
+func TestCollisionSetOperations(t *testing.T) {
+	forceCollisions(t, 0)
+
+	m1 := NewMap[string, int]().Set("a", 1).Set("b", 2).Set("c", 3)
+	m2 := NewMap[string, int]().Set("b", 20).Set("c", 30).Set("d", 4)
+
+	union := m1.Union(m2)
+	if union.Len() != 4 {
+		t.Errorf("union: expected len 4, got %d", union.Len())
+	}
+	if v, _ := union.Get("b"); v != 20 {
+		t.Errorf("union: expected b=20, got %d", v)
+	}
+
+	inter := m1.Intersection(m2)
+	if inter.Len() != 2 || !inter.Has("b") || !inter.Has("c") {
+		t.Errorf("intersection: unexpected result with len %d", inter.Len())
+	}
+
+	diff := m1.Difference(m2)
+	if diff.Len() != 1 || !diff.Has("a") {
+		t.Errorf("difference: unexpected result with len %d", diff.Len())
+	}
+
+	sym := m1.SymmetricDifference(m2)
+	if sym.Len() != 2 || !sym.Has("a") || !sym.Has("d") {
+		t.Errorf("symmetric difference: unexpected result with len %d", sym.Len())
+	}
+
+	if !m1.Equal(NewMap[string, int]().Set("c", 3).Set("a", 1).Set("b", 2)) {
+		t.Error("maps with the same colliding entries should be equal")
+	}
+}
+
 // Tests for public Map API
 
 func TestMapGetSet(t *testing.T) {
@@ -247,6 +460,174 @@ func TestMapDelete(t *testing.T) {
 	}
 }
 
+func TestMapUpdate(t *testing.T) {
+	incr := func(n Option[int]) Option[int] { return Some(UnwrapOr(n, 0) + 1) }
+	drop := func(Option[int]) Option[int] { return None[int]() }
+
+	var m Map[string, int]
+	for _, w := range []string{"a", "b", "a", "c", "a"} {
+		m = m.Update(w, incr)
+	}
+	if m.Len() != 3 {
+		t.Errorf("expected len 3, got %d", m.Len())
+	}
+	if v, _ := m.Get("a"); v != 3 {
+		t.Errorf("expected a=3, got %d", v)
+	}
+
+	// None removes the key
+	m2 := m.Update("b", drop)
+	if m2.Len() != 2 || m2.Has("b") {
+		t.Errorf("expected 'b' to be removed, got len %d", m2.Len())
+	}
+	if !m.Has("b") {
+		t.Error("original should still have 'b'")
+	}
+
+	// None on a missing key changes nothing
+	m3 := m.Update("missing", drop)
+	if m3.Len() != 3 || !m3.root.same(m.root) {
+		t.Error("expected a no-op update to leave the trie unchanged")
+	}
+
+	// f sees the current value
+	var seen []Option[int]
+	m.Update("a", func(n Option[int]) Option[int] {
+		seen = append(seen, n)
+		return n
+	}).Update("z", func(n Option[int]) Option[int] {
+		seen = append(seen, n)
+		return n
+	})
+	if len(seen) != 2 || Unwrap(seen[0]) != 3 || HasValue(seen[1]) {
+		t.Errorf("expected f to see Some(3) then None, got %v", seen)
+	}
+}
+
+func TestMapUpdateMany(t *testing.T) {
+	for _, mask := range []uint64{ones, 0x7, 0} {
+		t.Run(fmt.Sprintf("mask=%#x", mask), func(t *testing.T) {
+			forceCollisions(t, mask)
+
+			want := make(map[int]int)
+			var m Map[int, int]
+			b := NewBuilder[int, int]()
+			for i := range 2000 {
+				k := (i * 7) % 300
+				f := func(n Option[int]) Option[int] {
+					if v, ok := n.unwrap(); ok && v%3 == 0 {
+						return None[int]()
+					}
+					return Some(UnwrapOr(n, 0) + 1)
+				}
+				m = m.Update(k, f)
+				b.Update(k, f)
+				if v, ok := want[k]; ok && v%3 == 0 {
+					delete(want, k)
+				} else {
+					want[k]++
+				}
+			}
+			checkAgainst(t, "Map", m, want)
+			checkAgainst(t, "Builder", b.Build(), want)
+		})
+	}
+}
+
+// nodeCount returns the number of nodes in the trie rooted at n.
+func (n node[K, V]) nodeCount() int {
+	c := 1
+	for _, child := range n.nodes {
+		c += child.nodeCount()
+	}
+	return c
+}
+
+func TestCanonicalShape(t *testing.T) {
+	for _, mask := range []uint64{ones, 0x3ff, 0x7, 0} {
+		t.Run(fmt.Sprintf("mask=%#x", mask), func(t *testing.T) {
+			forceCollisions(t, mask)
+			r := rand.New(rand.NewPCG(1, 2))
+			drop := func(Option[int]) Option[int] { return None[int]() }
+
+			var want Map[int, int]
+			for i := range 300 {
+				want = want.Set(i, i)
+			}
+
+			// Insert extra keys in random order, then remove them in every way
+			var churned Map[int, int]
+			for _, i := range r.Perm(1000) {
+				churned = churned.Set(i, i)
+			}
+			var extra Map[int, int]
+			for _, i := range r.Perm(700) {
+				k := i + 300
+				switch i % 3 {
+				case 0:
+					churned = churned.Delete(k)
+				case 1:
+					churned = churned.Update(k, drop)
+				case 2:
+					extra = extra.Set(k, k)
+				}
+			}
+			churned = churned.Batch(func(b *Builder[int, int]) {
+				for k := range extra.KeysSeq() {
+					if k%2 == 0 {
+						b.Delete(k)
+					} else {
+						b.Update(k, drop)
+					}
+				}
+			})
+			churned = churned.Union(extra).Difference(extra)
+
+			if !churned.Equal(want) {
+				t.Fatalf("expected churned map to equal a fresh one")
+			}
+			if !churned.root.equal(defaultHasher[int]{}, want.root, func(a, b int) bool { return a == b }, 0) {
+				t.Error("expected churned map to have the canonical shape")
+			}
+			if got, exp := churned.root.nodeCount(), want.root.nodeCount(); got != exp {
+				t.Errorf("expected %d nodes after churn, got %d", exp, got)
+			}
+
+			// Deleting down to one key leaves it inline at the root
+			for i := 1; i < 300; i++ {
+				churned = churned.Delete(i)
+			}
+			if churned.root.nodeCount() != 1 || len(churned.root.data) != 1 {
+				t.Errorf("expected a single inline leaf, got %d nodes", churned.root.nodeCount())
+			}
+		})
+	}
+}
+
+func TestMapEqualStructural(t *testing.T) {
+	var a Map[int, int]
+	for i := range 1000 {
+		a = a.Set(i, i)
+	}
+	b := a.Set(500, -1)
+
+	if a.Equal(b) || !a.Equal(b.Set(500, 500)) {
+		t.Error("Equal gave the wrong answer for maps sharing structure")
+	}
+	if !a.Equal(a.Delete(10).Set(10, 10)) {
+		t.Error("expected a deleted and restored key to compare equal")
+	}
+
+	// Maps with different hashers fall back to lookups
+	stable := NewStableMap[int, int](0)
+	for i := range 1000 {
+		stable = stable.Set(i, i)
+	}
+	if !a.Equal(stable) || !stable.Equal(a) || stable.Equal(b) {
+		t.Error("Equal gave the wrong answer across hashers")
+	}
+}
+
 func TestMapForEach(t *testing.T) {
 	var m Map[string, int]
 	m = m.Set("a", 1).Set("b", 2).Set("c", 3)
@@ -371,6 +752,52 @@ func TestMapKeysValues(t *testing.T) {
 	}
 }
 
+func TestMapAll(t *testing.T) {
+	m := NewMap[string, int]().Set("a", 1).Set("b", 2).Set("c", 3)
+
+	seen := make(map[string]int)
+	for k, v := range m.All() {
+		seen[k] = v
+	}
+	if len(seen) != 3 || seen["a"] != 1 || seen["b"] != 2 || seen["c"] != 3 {
+		t.Errorf("All: unexpected entries %v", seen)
+	}
+
+	count := 0
+	for range m.All() {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("expected All to stop after 1, got %d", count)
+	}
+}
+
+func TestMapKeysValuesSeq(t *testing.T) {
+	m := NewMap[string, int]().Set("a", 1).Set("b", 2).Set("c", 3)
+
+	keys := Collect(m.KeysSeq())
+	if len(keys) != 3 {
+		t.Errorf("expected 3 keys, got %d", len(keys))
+	}
+	for _, k := range keys {
+		if !m.Has(k) {
+			t.Errorf("unexpected key %s", k)
+		}
+	}
+
+	if total := Sum(m.ValuesSeq()); total != 6 {
+		t.Errorf("expected values to sum to 6, got %d", total)
+	}
+
+	// Keys and values come out in matching order
+	for i, v := range Collect(m.ValuesSeq()) {
+		if got, _ := m.Get(keys[i]); got != v {
+			t.Errorf("value %d does not match key %s", v, keys[i])
+		}
+	}
+}
+
 func TestMapFromAndToMap(t *testing.T) {
 	stdMap := map[string]int{"a": 1, "b": 2, "c": 3}
 
@@ -387,7 +814,7 @@ func TestMapFromAndToMap(t *testing.T) {
 	}
 
 	// Convert back
-	result := m.ToMap()
+	result := ToMap(m)
 	if len(result) != 3 {
 		t.Errorf("expected 3 entries, got %d", len(result))
 	}
@@ -398,6 +825,65 @@ func TestMapFromAndToMap(t *testing.T) {
 	}
 }
 
+func TestCollectMap(t *testing.T) {
+	m := CollectMap(Zip(slices.Values([]string{"a", "b", "a"}), slices.Values([]int{1, 2, 3})))
+	if want := MapFromPairs[string, int]("a", 3, "b", 2); !m.Equal(want) {
+		t.Errorf("expected the last value to win, got %v", ToMap(m))
+	}
+	if CollectMap(NewMap[int, int]().All()).Len() != 0 {
+		t.Error("expected an empty map")
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	words := []string{"go", "map", "fn", "trie", "set", "a", "vec"}
+	groups := GroupBy(slices.Values(words), func(w string) int { return len(w) })
+	if groups.Len() != 4 {
+		t.Errorf("expected 4 groups, got %d", groups.Len())
+	}
+	want := map[int]string{1: "[a]", 2: "[go fn]", 3: "[map set vec]", 4: "[trie]"}
+	for k, g := range groups.All() {
+		if got := fmt.Sprint(g); got != want[k] {
+			t.Errorf("group %d: expected %s, got %s", k, want[k], got)
+		}
+	}
+
+	// Appends to a published group allocate, so they never alias each other
+	g, _ := groups.Get(3)
+	if cap(g) != len(g) {
+		t.Errorf("expected group 3 to be clipped, got len %d cap %d", len(g), cap(g))
+	}
+	x, y := append(g, "xx"), append(g, "yy")
+	if x[3] != "xx" || y[3] != "yy" {
+		t.Errorf("expected appends not to alias, got %v and %v", x, y)
+	}
+	if g2, _ := groups.Get(3); fmt.Sprint(g2) != "[map set vec]" {
+		t.Errorf("expected the group to be unchanged, got %v", g2)
+	}
+
+	if GroupBy(slices.Values([]int{}), func(int) int { return 0 }).Len() != 0 {
+		t.Error("expected no groups for an empty sequence")
+	}
+}
+
+func TestIndexBy(t *testing.T) {
+	type user struct {
+		ID   int
+		Name string
+	}
+	users := []user{{1, "ann"}, {2, "bob"}, {3, "cy"}}
+	byID := Unwrap(IndexBy(slices.Values(users), func(u user) int { return u.ID }))
+	if u, _ := byID.Get(2); byID.Len() != 3 || u.Name != "bob" {
+		t.Errorf("expected 3 users with 2 -> bob, got %v", ToMap(byID))
+	}
+
+	dup := IndexBy(slices.Values(append(users, user{2, "bo"})), func(u user) int { return u.ID })
+	_, err := Unpack(dup)
+	if !errors.Is(err, ErrDuplicateKey) || !strings.Contains(err.Error(), "2") {
+		t.Errorf("expected a duplicate key error naming 2, got %v", err)
+	}
+}
+
 func TestMapUnion(t *testing.T) {
 	m1 := NewMap[string, int]().Set("a", 1).Set("b", 2)
 	m2 := NewMap[string, int]().Set("b", 20).Set("c", 3)
@@ -501,6 +987,73 @@ func TestMapFilter(t *testing.T) {
 	}
 }
 
+func TestMapValues(t *testing.T) {
+	b := NewBuilder[int, int]()
+	for i := range 5000 {
+		b.Set(i, i)
+	}
+	m := b.Build().Delete(17)
+
+	got := MapValues(m, func(k, v int) string { return fmt.Sprint(k + v) })
+	want := NewMap[int, string]()
+	for k, v := range m.All() {
+		want = want.Set(k, fmt.Sprint(k+v))
+	}
+	if !got.Equal(want) || got.Len() != m.Len() {
+		t.Fatal("expected every value to be mapped")
+	}
+	// Only the byte estimate may differ, as the value types differ in size
+	gs, ms := got.Stats(), m.Stats()
+	gs.Bytes, ms.Bytes = 0, 0
+	if fmt.Sprint(gs) != fmt.Sprint(ms) {
+		t.Errorf("expected the same shape, got %+v and %+v", gs, ms)
+	}
+	if v, _ := m.Get(10); v != 10 {
+		t.Error("expected the source map to be unchanged")
+	}
+	if !got.Set(-1, "x").Has(-1) || got.Has(-1) {
+		t.Error("expected the result to be an independent persistent map")
+	}
+
+	folded := MapValues(NewMapWith[string, int](FoldHasher{}).Set("Key", 1), func(_ string, v int) bool { return v > 0 })
+	if v, ok := folded.Get("KEY"); !ok || !v {
+		t.Error("expected the result to keep the hasher")
+	}
+}
+
+func TestTryMapValues(t *testing.T) {
+	m := NewMap[string, string]().Set("a", "1").Set("b", "2").Set("c", "x").Set("d", "4")
+
+	calls := 0
+	r := TryMapValues(m, func(_ string, v string) (int, error) {
+		calls++
+		return strconv.Atoi(v)
+	})
+	if _, err := Unpack(r); err == nil {
+		t.Error("expected the parse error")
+	}
+	if calls > m.Len() || calls == 0 {
+		t.Errorf("unexpected %d calls", calls)
+	}
+
+	var visited []string
+	TryMapValues(m, func(k string, v string) (int, error) {
+		visited = append(visited, k)
+		if k == "c" {
+			return 0, errors.New("stop")
+		}
+		return 0, nil
+	})
+	if visited[len(visited)-1] != "c" {
+		t.Errorf("expected to stop at the first error, visited %v", visited)
+	}
+
+	ok := Unwrap(TryMapValues(m.Delete("c"), func(_ string, v string) (int, error) { return strconv.Atoi(v) }))
+	if want := MapFromPairs[string, int]("a", 1, "b", 2, "d", 4); !ok.Equal(want) {
+		t.Errorf("expected %v, got %v", ToMap(want), ToMap(ok))
+	}
+}
+
 func TestMapEqual(t *testing.T) {
 	m1 := NewMap[string, int]().Set("a", 1).Set("b", 2)
 	m2 := NewMap[string, int]().Set("a", 1).Set("b", 2)
@@ -520,6 +1073,63 @@ func TestMapEqual(t *testing.T) {
 	}
 }
 
+func TestMapEqualValues(t *testing.T) {
+	// Slices and maps are not comparable with ==
+	slices1 := NewMap[string, []int]().Set("a", []int{1, 2})
+	slices2 := NewMap[string, []int]().Set("a", []int{1, 2})
+	if !slices1.Equal(slices2) || slices1.Equal(slices2.Set("a", []int{1})) {
+		t.Error("Equal gave the wrong answer for slice values")
+	}
+
+	// Values with an Equal method use it
+	utc := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	local := utc.In(time.FixedZone("X", 3600))
+	if !NewMap[int, time.Time]().Set(1, utc).Equal(NewMap[int, time.Time]().Set(1, local)) {
+		t.Error("expected times at the same instant to be equal")
+	}
+	inner := NewMap[string, int]().Set("x", 1)
+	nested := NewMap[string, Map[string, int]]().Set("m", inner)
+	if !nested.Equal(NewMap[string, Map[string, int]]().Set("m", inner.Delete("x").Set("x", 1))) {
+		t.Error("expected nested maps to compare by contents")
+	}
+
+	// Interface values of different or non-comparable dynamic types
+	anys := NewMap[int, any]().Set(1, 1).Set(2, []string{"a"})
+	if !anys.Equal(NewMap[int, any]().Set(1, 1).Set(2, []string{"a"})) {
+		t.Error("expected equal interface values to compare equal")
+	}
+	if anys.Equal(NewMap[int, any]().Set(1, "1").Set(2, []string{"a"})) {
+		t.Error("expected values of different types to differ")
+	}
+}
+
+func TestMapEqualFunc(t *testing.T) {
+	a := NewMap[string, string]().Set("x", "Hello").Set("y", "World")
+	b := NewMap[string, string]().Set("x", "hello").Set("y", "WORLD")
+	if a.Equal(b) || !a.EqualFunc(b, strings.EqualFold) {
+		t.Error("expected EqualFunc to use the given comparison")
+	}
+
+	// Shared subtrees are not compared
+	var big Map[int, int]
+	for i := range 10000 {
+		big = big.Set(i, i)
+	}
+	calls := 0
+	if !big.EqualFunc(big.Set(5, 5), func(x, y int) bool { calls++; return x == y }) {
+		t.Error("expected maps to be equal")
+	}
+	if calls > 100 {
+		t.Errorf("expected shared subtrees to be skipped, compared %d values", calls)
+	}
+
+	// Maps with different hashers fall back to lookups
+	folded := NewMapWith[string, string](FoldHasher{}).Set("x", "hello").Set("y", "world")
+	if !folded.EqualFunc(a, strings.EqualFold) {
+		t.Error("expected EqualFunc to work across hashers")
+	}
+}
+
 func TestMapMerge(t *testing.T) {
 	m1 := NewMap[string, int]().Set("a", 1)
 	m2 := NewMap[string, int]().Set("b", 2)
@@ -579,6 +1189,29 @@ func TestBuilderDelete(t *testing.T) {
 	if m.Has("b") {
 		t.Error("expected 'b' to be deleted")
 	}
+
+	// Deleting a missing key leaves the source's nodes shared
+	b = m.ToBuilder()
+	b.Delete("missing")
+	if b.edit != nil || b.root.edit != m.root.edit {
+		t.Error("expected deleting a missing key to copy nothing")
+	}
+}
+
+func TestBuilderUpdateFunc(t *testing.T) {
+	b := NewMap[string, int]().Set("a", 1).ToBuilder()
+	b.Update("a", func(n Option[int]) Option[int] { return Some(Unwrap(n) * 10) })
+	b.Update("b", func(n Option[int]) Option[int] { return Some(UnwrapOr(n, 5)) })
+	b.Update("c", func(n Option[int]) Option[int] { return n })
+	if b.Len() != 2 {
+		t.Errorf("expected len 2, got %d", b.Len())
+	}
+	b.Update("b", func(Option[int]) Option[int] { return None[int]() })
+
+	m := b.Build()
+	if v, _ := m.Get("a"); v != 10 || m.Len() != 1 || m.Has("b") || m.Has("c") {
+		t.Errorf("expected only a=10, got %v", ToMap(m))
+	}
 }
 
 func TestBuilderManyKeys(t *testing.T) {
@@ -601,6 +1234,130 @@ func TestBuilderManyKeys(t *testing.T) {
 	}
 }
 
+func TestBuilderUseAfterBuild(t *testing.T) {
+	b := NewBuilder[string, int]()
+	b.Set("a", 1)
+	m := b.Build()
+
+	for name, use := range map[string]func(){
+		"Set":    func() { b.Set("b", 2) },
+		"Delete": func() { b.Delete("a") },
+		"Build":  func() { b.Build() },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected %s after Build to panic", name)
+				}
+			}()
+			use()
+		}()
+	}
+
+	if m.Len() != 1 || m.Has("b") {
+		t.Error("built map should be unaffected by later builder use")
+	}
+}
+
+func TestMapToBuilder(t *testing.T) {
+	var m Map[int, int]
+	for i := range 1000 {
+		m = m.Set(i, i)
+	}
+
+	b := m.ToBuilder()
+	for i := range 500 {
+		b.Set(i, -i)
+	}
+	for i := 900; i < 1100; i++ {
+		b.Delete(i)
+	}
+	b.Set(2000, 2000)
+	if b.Len() != 901 {
+		t.Errorf("expected builder len 901, got %d", b.Len())
+	}
+	edited := b.Build()
+
+	// The source map is untouched
+	if m.Len() != 1000 || m.root.count() != 1000 {
+		t.Fatalf("source map changed: len %d, count %d", m.Len(), m.root.count())
+	}
+	for i := range 1000 {
+		if v, ok := m.Get(i); !ok || v != i {
+			t.Fatalf("source map key %d: expected %d, got %d, %v", i, i, v, ok)
+		}
+	}
+	if m.Has(2000) {
+		t.Error("source map should not have 2000")
+	}
+
+	if edited.Len() != 901 || edited.root.count() != 901 {
+		t.Errorf("expected edited len 901, got %d (count %d)", edited.Len(), edited.root.count())
+	}
+	for i := range 900 {
+		want := i
+		if i < 500 {
+			want = -i
+		}
+		if v, ok := edited.Get(i); !ok || v != want {
+			t.Errorf("edited key %d: expected %d, got %d, %v", i, want, v, ok)
+		}
+	}
+	if edited.Has(950) || !edited.Has(2000) {
+		t.Error("edited map has unexpected membership")
+	}
+}
+
+func TestMapToBuilderIndependent(t *testing.T) {
+	m := NewMap[string, int]().Set("a", 1).Set("b", 2)
+
+	b1, b2 := m.ToBuilder(), m.ToBuilder()
+	b1.Set("a", 10).Delete("b")
+	b2.Set("c", 3)
+	m1, m2 := b1.Build(), b2.Build()
+
+	if v, _ := m.Get("a"); v != 1 || m.Len() != 2 {
+		t.Error("source map should be unchanged")
+	}
+	if v, _ := m1.Get("a"); v != 10 || m1.Len() != 1 {
+		t.Errorf("m1: expected {a: 10}, got len %d", m1.Len())
+	}
+	if v, _ := m2.Get("a"); v != 1 || m2.Len() != 3 {
+		t.Errorf("m2: expected a=1 and len 3, got a=%d len %d", v, m2.Len())
+	}
+
+	// A builder seeded from a built map must not mutate that map's nodes
+	m3 := m1.Batch(func(b *Builder[string, int]) { b.Set("a", 100) })
+	if v, _ := m1.Get("a"); v != 10 {
+		t.Errorf("m1 changed by a later batch: a=%d", v)
+	}
+	if v, _ := m3.Get("a"); v != 100 {
+		t.Errorf("expected a=100, got %d", v)
+	}
+}
+
+func TestMapBatchCollisions(t *testing.T) {
+	forceCollisions(t, 0)
+
+	m := NewMap[int, int]().Set(1, 1).Set(2, 2).Set(3, 3)
+	edited := m.Batch(func(b *Builder[int, int]) {
+		b.Set(2, 20).Delete(3).Set(4, 4)
+	})
+
+	if m.Len() != 3 || m.root.count() != 3 {
+		t.Fatal("source map changed")
+	}
+	if v, _ := m.Get(2); v != 2 {
+		t.Errorf("source map: expected 2=2, got %d", v)
+	}
+	if edited.Len() != 3 || edited.Has(3) || !edited.Has(4) {
+		t.Errorf("edited map has unexpected contents, len %d", edited.Len())
+	}
+	if v, _ := edited.Get(2); v != 20 {
+		t.Errorf("edited map: expected 2=20, got %d", v)
+	}
+}
+
 func TestMapJSON(t *testing.T) {
 	m := NewMap[string, int]().Set("a", 1).Set("b", 2).Set("c", 3)
 
@@ -626,6 +1383,71 @@ func TestMapJSON(t *testing.T) {
 	}
 }
 
+// textKey is a key type that converts to and from JSON through its text form.
+type textKey struct{ major, minor int }
+
+func (k textKey) MarshalText() ([]byte, error) {
+	return fmt.Appendf(nil, "%d.%d", k.major, k.minor), nil
+}
+
+func (k *textKey) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%d.%d", &k.major, &k.minor)
+	return err
+}
+
+func TestMapJSONKeys(t *testing.T) {
+	// Output matches encoding/json byte for byte, including key order
+	ints := map[int]string{10: "ten", -3: "minus three", 2: "two", 1 << 40: "big"}
+	checkJSON(t, MapFrom(ints), ints)
+
+	type level uint8
+	levels := map[level]bool{3: true, 250: false, 0: true}
+	checkJSON(t, MapFrom(levels), levels)
+
+	texts := map[textKey]int{{1, 2}: 12, {10, 0}: 100, {0, 9}: 9}
+	checkJSON(t, MapFrom(texts), texts)
+
+	strs := map[string]int{"b": 2, "a<&>": 1, "": 0}
+	checkJSON(t, MapFrom(strs), strs)
+
+	var bad Map[int8, int]
+	if err := json.Unmarshal([]byte(`{"300": 1}`), &bad); err == nil {
+		t.Error("expected an error for a key that overflows its type")
+	}
+	if err := json.Unmarshal([]byte(`{"x": 1}`), &bad); err == nil {
+		t.Error("expected an error for a non-numeric integer key")
+	}
+	var floats Map[float64, int]
+	if _, err := json.Marshal(floats); err == nil {
+		t.Error("expected an error marshaling float keys")
+	}
+	if err := json.Unmarshal([]byte(`{"1.5": 1}`), &floats); err == nil {
+		t.Error("expected an error unmarshaling float keys")
+	}
+}
+
+// checkJSON verifies that m marshals exactly like the equivalent Go map and
+// unmarshals back to the same entries.
+func checkJSON[K comparable, V comparable](t *testing.T, m Map[K, V], want map[K]V) {
+	t.Helper()
+	got, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	exp, _ := json.Marshal(want)
+	if string(got) != string(exp) {
+		t.Errorf("expected %s, got %s", exp, got)
+	}
+
+	var back Map[K, V]
+	if err := json.Unmarshal(got, &back); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if !back.Equal(m) {
+		t.Errorf("expected %s to round trip, got %v", got, ToMap(back))
+	}
+}
+
 func TestMapJSONRoundTrip(t *testing.T) {
 	original := MapFrom(map[string]string{
 		"name":  "test",
@@ -658,9 +1480,10 @@ func BenchmarkTrieInsert(b *testing.B) {
 			b.ReportAllocs()
 			for range b.N {
 				var root node[int, int]
+				hs := defaultHasher[int]{}
 				for i := range size {
 					h := hash(i)
-					root = root.insert(i, i, h, 0)
+					root = setNode(root, hs, i, i, h)
 				}
 			}
 		})
@@ -683,6 +1506,39 @@ func BenchmarkBuilderInsert(b *testing.B) {
 	}
 }
 
+// BenchmarkBatchEdits compares applying many edits to an existing Map through
+// Batch against chaining Set
+func BenchmarkBatchEdits(b *testing.B) {
+	for _, size := range sizes {
+		base := NewBuilder[int, int]()
+		for i := range size {
+			base.Set(i, i)
+		}
+		m := base.Build()
+
+		b.Run(fmt.Sprintf("Set/size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				x := m
+				for i := range size {
+					x = x.Set(i, -i)
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("Batch/size=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				_ = m.Batch(func(bld *Builder[int, int]) {
+					for i := range size {
+						bld.Set(i, -i)
+					}
+				})
+			}
+		})
+	}
+}
+
 // BenchmarkMapInsert measures insert performance for built-in map
 func BenchmarkMapInsert(b *testing.B) {
 	for _, size := range sizes {
@@ -703,9 +1559,10 @@ func BenchmarkTrieGet(b *testing.B) {
 	for _, size := range sizes {
 		// Pre-build the trie
 		var root node[int, int]
+		hs := defaultHasher[int]{}
 		for i := range size {
 			h := hash(i)
-			root = root.insert(i, i, h, 0)
+			root = setNode(root, hs, i, i, h)
 		}
 
 		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
@@ -713,7 +1570,7 @@ func BenchmarkTrieGet(b *testing.B) {
 			for range b.N {
 				for i := range size {
 					h := hash(i)
-					root.get(i, h, 0)
+					root.get(hs, i, h, 0)
 				}
 			}
 		})
@@ -745,9 +1602,10 @@ func BenchmarkTrieUpdate(b *testing.B) {
 	for _, size := range sizes {
 		// Pre-build the trie
 		var root node[int, int]
+		hs := defaultHasher[int]{}
 		for i := range size {
 			h := hash(i)
-			root = root.insert(i, i, h, 0)
+			root = setNode(root, hs, i, i, h)
 		}
 
 		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
@@ -755,7 +1613,7 @@ func BenchmarkTrieUpdate(b *testing.B) {
 			h := hash(0)
 			for range b.N {
 				// Update a single key - this creates a new root with path copying
-				_ = root.insert(0, 999, h, 0)
+				_ = setNode(root, hs, 0, 999, h)
 			}
 		})
 	}
@@ -783,9 +1641,10 @@ func BenchmarkMapUpdate(b *testing.B) {
 func BenchmarkTrieMixedOps(b *testing.B) {
 	for _, size := range sizes {
 		var root node[int, int]
+		hs := defaultHasher[int]{}
 		for i := range size {
 			h := hash(i)
-			root = root.insert(i, i, h, 0)
+			root = setNode(root, hs, i, i, h)
 		}
 
 		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
@@ -794,10 +1653,10 @@ func BenchmarkTrieMixedOps(b *testing.B) {
 				h := hash(i % size)
 				if i%10 == 0 {
 					// 10% writes
-					root = root.insert(i%size, i, h, 0)
+					root = setNode(root, hs, i%size, i, h)
 				} else {
 					// 90% reads
-					root.get(i%size, h, 0)
+					root.get(hs, i%size, h, 0)
 				}
 			}
 		})
@@ -834,9 +1693,10 @@ func BenchmarkMemoryUsage(b *testing.B) {
 			b.ReportAllocs()
 			for range b.N {
 				var root node[int, int]
+				hs := defaultHasher[int]{}
 				for i := range size {
 					h := hash(i)
-					root = root.insert(i, i, h, 0)
+					root = setNode(root, hs, i, i, h)
 				}
 				// Prevent optimization
 				if root.isEmpty() {
@@ -18,4 +18,12 @@ func (v Vec[T]) Iter() iter.Seq[T] {
 	return slices.Values(v)
 }
 
-var _ Iterable[int] = make(Vec[int], 0)
+// All implements [Iterable2], yielding index-value pairs.
+func (v Vec[T]) All() iter.Seq2[int, T] {
+	return slices.All(v)
+}
+
+var (
+	_ Iterable[int]       = make(Vec[int], 0)
+	_ Iterable2[int, int] = make(Vec[int], 0)
+)